@@ -0,0 +1,236 @@
+package lolcheBot
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	textDenied    = "죄송합니다. 이 bot을 사용할 권한이 없습니다. 관리자에게 id %d 등록을 요청해 주세요."
+	textAdminOnly = "죄송합니다. 관리자만 사용할 수 있는 명령입니다."
+
+	deniedNoticeInterval = 10 * time.Minute // 같은 chat에 권한 없음 안내를 다시 보내기까지의 간격
+)
+
+// access는 설정 파일의 권한. /grant로 부여한 권한과 합쳐 판단한다.
+type access struct {
+	allow  []int64 // 사용할 수 있는 user id, chat id
+	admins []int64 // 관리자 user id
+	denied *deniedNotices
+}
+
+// deniedNotices는 chat별 마지막 권한 없음 안내 시각
+type deniedNotices struct {
+	mu   sync.Mutex
+	last map[int64]time.Time
+}
+
+// notify는 chat에 권한 없음 안내를 보내도 되는지 여부. 보내도 되면 now를 기록한다.
+func (d *deniedNotices) notify(chatId int64, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if last, ok := d.last[chatId]; ok && now.Sub(last) < deniedNoticeInterval {
+		return false
+	}
+	d.last[chatId] = now
+	return true
+}
+
+// newAccess는 설정 파일의 권한. chatId는 항상 사용할 수 있고, 관리자를 지정하지 않았으면 chatId(개인 chat이면 user id와 같다)가 관리자다.
+func newAccess(chatId int64, allow []int64, admins []int64) access {
+	a := access{allow: slices.Clone(allow), admins: slices.Clone(admins), denied: &deniedNotices{last: make(map[int64]time.Time)}}
+	if chatId != 0 {
+		a.allow = append(a.allow, chatId)
+	}
+	if len(a.admins) == 0 && chatId > 0 {
+		a.admins = []int64{chatId}
+	}
+	return a
+}
+
+// role은 user가 chat에서 가진 권한. 없으면 빈 값. granted는 /grant로 부여한 권한 조회
+func (a access) role(userId int64, chatId int64, granted func(id int64) (Role, error)) (Role, error) {
+	if slices.Contains(a.admins, userId) {
+		return RoleAdmin, nil
+	}
+	userRole, err := granted(userId)
+	if err != nil {
+		return "", err
+	}
+	if userRole == RoleAdmin {
+		return RoleAdmin, nil
+	}
+	if userRole != "" || slices.Contains(a.allow, userId) || slices.Contains(a.allow, chatId) {
+		return RoleMember, nil
+	}
+	chatRole, err := granted(chatId)
+	if err != nil {
+		return "", err
+	}
+	if chatRole != "" {
+		return RoleMember, nil
+	}
+	return "", nil
+}
+
+// configured는 설정 파일에 등록된 id인지 여부. /revoke로 제거할 수 없다.
+func (a access) configured(id int64) bool {
+	return slices.Contains(a.allow, id) || slices.Contains(a.admins, id)
+}
+
+// requiresAdmin은 관리자만 사용할 수 있는 명령인지 여부. /mode, /season은 변경할 때만 제한한다.
+func requiresAdmin(cmd Command, arg string) bool {
+	switch cmd {
+	case reset, switching, grant, revoke:
+		return true
+	case mode, season:
+		return arg != ""
+	}
+	return false
+}
+
+// role은 update를 보낸 사용자의 권한
+func (t TeleBot) role(update *tgbotapi.Update) (Role, error) {
+	var userId, chatId int64
+	if user := update.SentFrom(); user != nil {
+		userId = user.ID
+	}
+	if chat := update.FromChat(); chat != nil {
+		chatId = chat.ID
+	}
+	return t.access.role(userId, chatId, t.stg.Role)
+}
+
+// isRequest는 bot에 보낸 명령이나 버튼인지 여부. 그룹 chat의 일반 메시지에는 답하지 않는다.
+func isRequest(update *tgbotapi.Update) bool {
+	return update.CallbackQuery != nil || (update.Message != nil && strings.HasPrefix(update.Message.Text, "/"))
+}
+
+// authorize는 update를 처리할 권한이 있는지 확인하고, 관리자 여부도 반환한다.
+// 권한이 없으면 명령과 버튼에만 안내하고, 같은 chat에는 deniedNoticeInterval마다 한 번만 보낸다.
+func (t TeleBot) authorize(update *tgbotapi.Update) (admin bool, ok bool) {
+	role, err := t.role(update)
+	if err != nil {
+		log.Printf("권한 조회 실패. %s", err.Error())
+		if isRequest(update) {
+			t.SendMessage("권한 확인 중 오류가 발생했습니다. 잠시 후 다시 시도해 주세요.")
+		}
+		return false, false
+	}
+	if role == "" {
+		if !isRequest(update) {
+			return false, false
+		}
+		id := t.chatId
+		if user := update.SentFrom(); user != nil {
+			id = user.ID
+		}
+		if !t.access.denied.notify(t.chatId, time.Now()) {
+			log.Printf("권한 없는 요청 무시. chat %d, id %d", t.chatId, id)
+			return false, false
+		}
+		t.SendMessage(fmt.Sprintf(textDenied, id))
+		return false, false
+	}
+	return role == RoleAdmin, true
+}
+
+// grantJob은 id에 권한을 부여한다. ex) /grant 123, /grant 123 admin. id가 없으면 권한 목록을 보낸다.
+func (t TeleBot) grantJob(arg string) {
+	if arg == "" {
+		t.rolesJob()
+		return
+	}
+
+	idText, roleText, _ := strings.Cut(arg, " ")
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if err != nil {
+		t.SendMessage("잘못된 id. ex) /grant 123456789 또는 /grant 123456789 admin")
+		return
+	}
+	role := RoleMember
+	switch strings.TrimSpace(roleText) {
+	case "", string(RoleMember):
+	case string(RoleAdmin):
+		if id < 0 {
+			t.SendMessage("관리자 권한은 user id에만 부여할 수 있습니다.")
+			return
+		}
+		role = RoleAdmin
+	default:
+		t.SendMessage(fmt.Sprintf("없는 권한. %s (%s | %s)", roleText, RoleMember, RoleAdmin))
+		return
+	}
+
+	if err := t.stg.SaveRole(id, role); err != nil {
+		t.SendMessage(fmt.Sprintf("권한 부여 오류 발생. %s", err.Error()))
+		return
+	}
+	t.SendMessage(fmt.Sprintf("%d에 %s 권한 부여", id, role))
+}
+
+// revokeJob은 /grant로 부여한 권한을 회수한다. 설정 파일에 등록된 id는 설정 파일에서 제거해야 한다.
+func (t TeleBot) revokeJob(arg string) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		t.SendMessage("잘못된 id. ex) /revoke 123456789")
+		return
+	}
+	if err := t.stg.DeleteRole(id); err != nil {
+		t.SendMessage(fmt.Sprintf("권한 회수 오류 발생. %s", err.Error()))
+		return
+	}
+	if t.access.configured(id) {
+		t.SendMessage(fmt.Sprintf("%d는 설정 파일에 등록된 id라서 설정 파일에서 제거해야 합니다.", id))
+		return
+	}
+	t.SendMessage(fmt.Sprintf("%d 권한 회수", id))
+}
+
+// rolesJob은 설정 파일과 /grant로 부여한 권한 목록을 보낸다.
+func (t TeleBot) rolesJob() {
+	roles, err := t.stg.Roles()
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+	t.SendMessage(rolesText(t.access, roles))
+}
+
+// rolesText는 권한 목록 문구. ex)
+//
+//	[설정 파일]
+//	- 1 admin
+//	- -100 member
+//	[/grant]
+//	- 7 member
+func rolesText(a access, granted map[int64]Role) string {
+	lines := []string{"[설정 파일]"}
+	for _, id := range a.admins {
+		lines = append(lines, fmt.Sprintf("- %d %s", id, RoleAdmin))
+	}
+	for _, id := range a.allow {
+		if !slices.Contains(a.admins, id) {
+			lines = append(lines, fmt.Sprintf("- %d %s", id, RoleMember))
+		}
+	}
+
+	lines = append(lines, "[/grant]")
+	ids := slices.Sorted(maps.Keys(granted))
+	for _, id := range ids {
+		lines = append(lines, fmt.Sprintf("- %d %s", id, granted[id]))
+	}
+	if len(ids) == 0 {
+		lines = append(lines, "- 없음")
+	}
+	return strings.Join(lines, "\n")
+}
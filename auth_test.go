@@ -0,0 +1,149 @@
+package lolcheBot
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestNewAccess(t *testing.T) {
+
+	a := newAccess(10, nil, nil)
+	if !a.configured(10) {
+		t.Error("chatId should be allowed")
+	}
+	if got, _ := a.role(10, 10, noGrant); got != RoleAdmin {
+		t.Errorf("chatId should be admin without admins. got %q", got)
+	}
+
+	// 그룹 chat id는 관리자가 될 수 없다.
+	if group := newAccess(-100, nil, nil); len(group.admins) != 0 {
+		t.Errorf("group chat should not be admin. got %v", group.admins)
+	}
+
+	if got, _ := newAccess(10, nil, []int64{20}).role(10, 10, noGrant); got != RoleMember {
+		t.Errorf("chatId should be member when admins are set. got %q", got)
+	}
+}
+
+func TestAccessRole(t *testing.T) {
+
+	a := newAccess(0, []int64{-100, 3}, []int64{1})
+	granted := map[int64]Role{7: RoleMember, 8: RoleAdmin, -200: RoleMember}
+	lookup := func(id int64) (Role, error) { return granted[id], nil }
+
+	tests := []struct {
+		name   string
+		user   int64
+		chat   int64
+		expect Role
+	}{
+		{"설정 관리자", 1, 1, RoleAdmin},
+		{"설정 user", 3, 3, RoleMember},
+		{"설정 그룹의 사용자", 5, -100, RoleMember},
+		{"grant member", 7, 7, RoleMember},
+		{"grant admin", 8, -300, RoleAdmin},
+		{"grant 그룹의 사용자", 5, -200, RoleMember},
+		{"미등록", 5, 5, ""},
+		{"미등록 그룹", 5, -300, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := a.role(test.user, test.chat, lookup)
+			if err != nil || got != test.expect {
+				t.Errorf("expect %q. got %q %v", test.expect, got, err)
+			}
+		})
+	}
+
+	failed := errors.New("db error")
+	if _, err := a.role(5, 5, func(int64) (Role, error) { return "", failed }); !errors.Is(err, failed) {
+		t.Errorf("lookup error should be returned. got %v", err)
+	}
+}
+
+func TestRequiresAdmin(t *testing.T) {
+
+	tests := []struct {
+		cmd    Command
+		arg    string
+		expect bool
+	}{
+		{reset, "", true},
+		{switching, "", true},
+		{grant, "", true},
+		{revoke, "1", true},
+		{mode, "", false},
+		{mode, "pbe", true},
+		{season, "", false},
+		{season, "15", true},
+		{updating, "", false},
+		{help, "", false},
+	}
+	for _, test := range tests {
+		if got := requiresAdmin(test.cmd, test.arg); got != test.expect {
+			t.Errorf("%s %q expect %v. got %v", test.cmd, test.arg, test.expect, got)
+		}
+	}
+}
+
+func TestRolesText(t *testing.T) {
+
+	a := newAccess(1, []int64{-100}, nil)
+	expect := "[설정 파일]\n- 1 admin\n- -100 member\n[/grant]\n- 7 member\n- 8 admin"
+	if got := rolesText(a, map[int64]Role{8: RoleAdmin, 7: RoleMember}); got != expect {
+		t.Errorf("expect\n%s\ngot\n%s", expect, got)
+	}
+	if got := rolesText(a, nil); got != "[설정 파일]\n- 1 admin\n- -100 member\n[/grant]\n- 없음" {
+		t.Errorf("empty grant. got\n%s", got)
+	}
+}
+
+func noGrant(int64) (Role, error) { return "", nil }
+
+func TestAuthorize(t *testing.T) {
+
+	bot, tg := newTestBot(t, NewMemoryStorage(), NewScriptedCrawler())
+	stranger := &tgbotapi.User{ID: 9}
+	message := func(chatId int64, text string) *tgbotapi.Update {
+		return &tgbotapi.Update{Message: &tgbotapi.Message{Text: text, Chat: &tgbotapi.Chat{ID: chatId}, From: stranger}}
+	}
+	button := &tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: stranger, Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -300}}}}
+
+	for _, update := range []*tgbotapi.Update{
+		message(-200, "안녕하세요"),
+		message(-200, "/update"),
+		message(-200, "/done"),
+		button,
+		message(1, "/update"),
+	} {
+		bot.forUpdate(update).authorize(update)
+	}
+
+	want := []string{fmt.Sprintf(textDenied, 9), fmt.Sprintf(textDenied, 9)}
+	if texts := tg.texts(); !slices.Equal(texts, want) {
+		t.Errorf("only the first request of each chat should be answered. got %q", texts)
+	}
+}
+
+func TestDeniedNotices(t *testing.T) {
+
+	now := time.Now()
+	d := newAccess(1, nil, nil).denied
+	if !d.notify(-200, now) {
+		t.Error("first request should be answered")
+	}
+	if d.notify(-200, now.Add(deniedNoticeInterval-time.Second)) {
+		t.Error("request within interval should not be answered")
+	}
+	if !d.notify(-300, now) {
+		t.Error("chats should be limited separately")
+	}
+	if !d.notify(-200, now.Add(deniedNoticeInterval)) {
+		t.Error("request after interval should be answered")
+	}
+}
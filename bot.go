@@ -1,19 +1,27 @@
 package lolcheBot
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type TeleBot struct {
-	bot    *tgbotapi.BotAPI
-	chatId int64
-	stg    Stoage
-	dc     DeckCrawler
+	bot           *tgbotapi.BotAPI
+	chatId        int64 // 답장할 chat. 설정한 chat은 meta 변경 알림을 받는다.
+	owner         int64 // 기록 단위. chatId 또는 perUser면 그룹에서 메시지를 보낸 사용자 id
+	perUser       bool
+	access        access
+	stg           Stoage
+	dc            DeckCrawler
+	watchInterval time.Duration
+	successPlace  int
 }
 
 func NewTeleBot(conf *TeleBotConfig, stg Stoage, dc DeckCrawler) (*TeleBot, error) {
@@ -25,42 +33,75 @@ func NewTeleBot(conf *TeleBotConfig, stg Stoage, dc DeckCrawler) (*TeleBot, erro
 	// bot.Debug = true
 
 	return &TeleBot{
-		bot:    bot,
-		chatId: conf.chatId,
-		stg:    stg,
-		dc:     dc,
+		bot:           bot,
+		chatId:        conf.chatId,
+		owner:         conf.chatId,
+		perUser:       conf.perUser,
+		access:        newAccess(conf.chatId, conf.allow, conf.admins),
+		stg:           stg,
+		dc:            newHistoryRecorder(dc, stg),
+		watchInterval: conf.watchInterval,
+		successPlace:  conf.successPlace,
 	}, nil
 }
 
 type TeleBotConfig struct {
-	token  string
-	chatId int64
+	token         string
+	chatId        int64
+	perUser       bool
+	allow         []int64
+	admins        []int64
+	watchInterval time.Duration
+	successPlace  int
 }
 
-func NewTeleBotConfig(token string, chatId int64) *TeleBotConfig {
+// NewTeleBotConfig의 chatId는 meta 변경 알림을 받을 chat. chat 구분 도입 전 기록도 이 chat으로 옮겨진다.
+// perUser면 그룹 chat에서도 사용자별로 기록한다. allow는 사용할 수 있는 user id, chat id. admins는 관리자 user id.
+// admins가 없으면 chatId가 관리자다. watchInterval은 meta 변경 확인 주기. 0이면 확인하지 않는다.
+// successPlace는 덱을 자동으로 완료 처리할 등수. ex) 4면 4등 이내. 0이면 등수를 기록만 한다.
+func NewTeleBotConfig(token string, chatId int64, perUser bool, allow []int64, admins []int64, watchInterval time.Duration, successPlace int) *TeleBotConfig {
 
 	return &TeleBotConfig{
-		token:  token,
-		chatId: chatId,
+		token:         token,
+		chatId:        chatId,
+		perUser:       perUser,
+		allow:         allow,
+		admins:        admins,
+		watchInterval: watchInterval,
+		successPlace:  successPlace,
 	}
 }
 
-var candidateDeckMap map[string]string = map[string]string{}
-var doneDeckMap map[string]string = map[string]string{}
-
 // todo deck index +1
 func (t TeleBot) Run() { // channel 받아
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 	updates := t.bot.GetUpdatesChan(u)
 
+	if t.watchInterval > 0 {
+		go newMetaWatcher(t.dc, t.stg, t.owner, t.watchInterval, t.SendMessage).run()
+	}
+
 	for update := range updates {
+		if update.Message == nil && update.CallbackQuery == nil {
+			continue
+		}
+		t := t.forUpdate(&update)
+		admin, ok := t.authorize(&update)
+		if !ok {
+			continue
+		}
 		if update.Message != nil {
-			switch Command(update.Message.Text) {
+			cmd, arg, _ := strings.Cut(strings.TrimSpace(update.Message.Text), " ")
+			if requiresAdmin(Command(cmd), strings.TrimSpace(arg)) && !admin {
+				t.SendMessage(textAdminOnly)
+				continue
+			}
+			switch Command(cmd) {
 			case help:
 				t.helpJob()
 			case mode:
-				t.modeJob()
+				t.modeJob(strings.TrimSpace(arg))
 			case switching:
 				t.switchJob()
 			case updating:
@@ -69,6 +110,18 @@ func (t TeleBot) Run() { // channel 받아
 				t.resetJob()
 			case done:
 				t.doneJob()
+			case strategy:
+				t.strategyJob(strings.TrimSpace(arg))
+			case history:
+				t.historyJob(strings.TrimSpace(arg))
+			case season:
+				t.seasonJob(strings.TrimSpace(arg))
+			case stats:
+				t.statsJob()
+			case grant:
+				t.grantJob(strings.TrimSpace(arg))
+			case revoke:
+				t.revokeJob(strings.TrimSpace(arg))
 			// case fix:
 			// 	t.fixJob()
 			default:
@@ -78,22 +131,33 @@ func (t TeleBot) Run() { // channel 받아
 		}
 
 		if update.CallbackQuery != nil {
-			switch update.CallbackQuery.Message.Text {
-			case titleNormalDeck, titleSpecDeck:
-				t.selectJob(&update)
-			case titleWhetherCompleted:
-				t.completeJob(&update)
-			case titleCompletionList:
-				t.restoreJob(&update)
-			default:
+			err := t.callbacks().route(&update, admin)
+			switch {
+			case errors.Is(err, errCallbackForbidden):
+				t.SendMessage(textAdminOnly)
+			case err != nil:
+				log.Printf("callback %q 처리 불가. %s", update.CallbackQuery.Data, err.Error())
 				t.SendMessage("세션 완료. /update로 덱 갱신 필요")
 			}
-
 		}
 	}
 
 }
 
+// forUpdate는 update를 보낸 chat에 답하고 그 chat의 기록을 사용하는 bot. perUser면 그룹에서는 보낸 사용자의 기록을 사용한다.
+func (t TeleBot) forUpdate(update *tgbotapi.Update) TeleBot {
+	chat := update.FromChat()
+	if chat == nil {
+		return t
+	}
+	t.chatId = chat.ID
+	t.owner = chat.ID
+	if user := update.SentFrom(); t.perUser && !chat.IsPrivate() && user != nil {
+		t.owner = user.ID
+	}
+	return t
+}
+
 func (t TeleBot) helpJob() {
 	cmds := ""
 	for i, c := range allCommands() {
@@ -105,35 +169,99 @@ func (t TeleBot) helpJob() {
 	t.SendMessage(cmds)
 }
 
-func (t TeleBot) modeJob() {
-	mode := t.stg.Mode()
-	t.SendMessage("현재 모드: " + mode.Str())
+// modeJob은 모드 이름이 주어지면 모드를 바로 변경하고, 없으면 현재 모드와 선택 버튼을 보낸다.
+func (t TeleBot) modeJob(name string) {
+	if name != "" {
+		if !slices.Contains(t.dc.Modes(), Mode(name)) {
+			t.SendMessage(fmt.Sprintf("없는 모드. %s", name))
+			return
+		}
+		t.saveMode(Mode(name))
+		return
+	}
+
+	current, ok := t.currentMode()
+	if !ok {
+		return
+	}
+	t.SendMessage("현재 모드: " + current.Str())
+	opt := DecOptMsg{
+		Title: titleMode,
+	}
+	for _, m := range t.dc.Modes() {
+		label := m.Str()
+		if m == current {
+			label = "✅ " + label
+		}
+		opt.Rcmds = append(opt.Rcmds, string(m))
+		opt.Ids = append(opt.Ids, callback{Action: actMode, Key: string(m)}.data())
+		opt.Labels = append(opt.Labels, label)
+	}
+	t.sendOptions(&opt)
 }
 
+func (t TeleBot) chooseModeJob(update *tgbotapi.Update, cb callback) {
+	t.modeJob(cb.Key)
+}
+
+// switchJob은 다음 순서의 모드로 변경한다.
 func (t TeleBot) switchJob() {
-	mode := t.stg.Mode()
-	mode = !mode
-	t.stg.SaveMode(mode)
+	current, ok := t.currentMode()
+	if !ok {
+		return
+	}
+	modes := t.dc.Modes()
+	next := (slices.Index(modes, current) + 1) % len(modes)
+	t.saveMode(modes[next])
+}
 
+func (t TeleBot) saveMode(mode Mode) {
+	if err := t.stg.SaveMode(t.owner, mode); err != nil {
+		t.SendMessage(fmt.Sprintf("모드 변경 오류 발생. %s", err.Error()))
+		return
+	}
 	t.SendMessage("모드 변환 완료. 현재 모드: " + mode.Str())
 }
 
+// currentMode는 저장된 모드. 조회에 실패하면 오류를 보내고 false를 반환한다.
+func (t TeleBot) currentMode() (Mode, bool) {
+	mode, err := t.stg.Mode(t.owner)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("모드 조회 오류 발생. %s", err.Error()))
+		return "", false
+	}
+	return mode, true
+}
+
 func (t TeleBot) updateJob() {
-	mode := t.stg.Mode()
+	mode, ok := t.currentMode()
+	if !ok {
+		return
+	}
 
 	decLi, err := t.dc.Meta(mode)
-	doneLi, _ := t.stg.All(mode)
-
 	if err != nil {
 		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
 	} else {
-		decs := makeDecRcmd(decLi, doneLi)
+		doneLi, _ := t.stg.All(t.owner, mode)
+		lastAttempted, attemptedErr := t.stg.LastAttempted(t.owner, mode)
+		if attemptedErr != nil {
+			log.Printf("마지막 도전 시각 조회 실패. %s", attemptedErr.Error())
+			lastAttempted = nil
+		}
+		if fetchedAt, stale := t.dc.Stale(mode); stale {
+			t.SendMessage(fmt.Sprintf("lolchess.gg 접속 실패. %s(%s) 저장된 덱 정보로 추천합니다.", ago(time.Since(fetchedAt)), fetchedAt.Format("01/02 15:04")))
+		}
+		doneLi = t.relink(mode, decLi, doneLi)
+		decs := makeDecRcmd(mode, t.currentStrategy(), decLi, doneLi, lastAttempted)
 		if len(decs) > 0 {
+			pendings := make([]Pending, len(decLi))
+			for i, deck := range decLi {
+				pendings[i] = Pending{Kind: PendingDeck, Mode: mode, Id: deck.Key, Key: deck.Key, Name: deck.Name}
+			}
+			t.savePending(pendings...)
 			for i := 0; i < len(decs); i++ {
 				t.sendOptions(&decs[i])
-				for j := 0; j < len(decs[i].Rcmds); j++ {
-					candidateDeckMap[strconv.Itoa(decs[i].Ids[j])] = decs[i].Rcmds[j]
-				}
 			}
 
 		} else {
@@ -142,18 +270,12 @@ func (t TeleBot) updateJob() {
 	}
 }
 
-func (t TeleBot) resetJob() { // todo. 지우기전에 한번 물어봐
-	mode := t.stg.Mode()
-	err := t.stg.DeleteAll(mode)
-	if err != nil {
-		t.SendMessage(fmt.Sprintf("%s 기록 삭제 오류 발생. %s", mode.Str(), err.Error()))
-	}
-	t.SendMessage(fmt.Sprintf("%s 기록 삭제 완료", mode.Str()))
-}
-
 func (t TeleBot) doneJob() {
-	mode := t.stg.Mode()
-	doneLi, err := t.stg.All(mode)
+	mode, ok := t.currentMode()
+	if !ok {
+		return
+	}
+	doneLi, err := t.stg.All(t.owner, mode)
 	if err != nil {
 		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
 		return
@@ -163,12 +285,171 @@ func (t TeleBot) doneJob() {
 		return
 	}
 
-	dec := makeDecDone(doneLi)
+	nonce := newNonce()
+	dec := makeDecDone(mode, nonce, doneLi)
+	pendings := make([]Pending, len(doneLi))
+	for i, d := range doneLi {
+		id := pendingId(callback{Key: strconv.Itoa(i), Nonce: nonce})
+		pendings[i] = Pending{Kind: PendingDone, Mode: mode, Id: id, Key: d.Key, Name: d.Name}
+	}
+	t.savePending(pendings...)
 	t.sendOptions(&dec)
-	for j := 0; j < len(dec.Rcmds); j++ {
-		doneDeckMap[strconv.Itoa(dec.Ids[j])] = dec.Rcmds[j]
+}
+
+// relink는 key 없이 저장된 완료 기록 중 crawl 결과와 확실히 매칭되는 기록에 key를 부여하고, 갱신된 완료 목록을 반환한다.
+func (t TeleBot) relink(mode Mode, decLi []Deck, doneLi []DoneDeck) []DoneDeck {
+	links := reconcile(decLi, doneLi)
+	for i, deck := range links {
+		if err := t.stg.Relink(t.owner, mode, doneLi[i].Name, deck.Key, deck.Name); err != nil {
+			log.Printf("relink %s 실패. %s", doneLi[i].Name, err.Error())
+			continue
+		}
+		doneLi[i] = DoneDeck{Key: deck.Key, Name: deck.Name}
+	}
+	return doneLi
+}
+
+// strategyJob은 이름이 주어지면 추천 방식을 바로 변경하고, 없으면 선택 버튼을 보낸다.
+func (t TeleBot) strategyJob(name string) {
+	if name != "" {
+		st, ok := findStrategy(name)
+		if !ok {
+			t.SendMessage(fmt.Sprintf("없는 추천 방식. %s", name))
+			return
+		}
+		t.saveStrategy(st)
+		return
+	}
+
+	current := t.currentStrategy()
+	opt := DecOptMsg{
+		Title: titleStrategy,
+	}
+	for i, st := range allStrategies() {
+		label := fmt.Sprintf("%s - %s", st.Name(), st.Desc())
+		if st.Name() == current.Name() {
+			label = "✅ " + label
+		}
+		opt.Rcmds = append(opt.Rcmds, st.Name())
+		opt.Ids = append(opt.Ids, callback{Action: actStrategy, Key: strconv.Itoa(i)}.data())
+		opt.Labels = append(opt.Labels, label)
+	}
+	t.sendOptions(&opt)
+}
+
+func (t TeleBot) chooseStrategyJob(update *tgbotapi.Update, cb callback) {
+	sts := allStrategies()
+	i, err := strconv.Atoi(cb.Key)
+	if err != nil || i < 0 || i >= len(sts) {
+		t.SendMessage("서버 오류 발생. 잘못된 추천 방식 id")
+		return
+	}
+	t.saveStrategy(sts[i])
+}
+
+func (t TeleBot) saveStrategy(st Strategy) {
+	if err := t.stg.SaveStrategy(t.owner, st.Name()); err != nil {
+		t.SendMessage(fmt.Sprintf("추천 방식 저장 오류 발생. %s", err.Error()))
+		return
+	}
+	t.SendMessage(fmt.Sprintf("추천 방식 변경 완료. 현재 방식: %s - %s", st.Name(), st.Desc()))
+}
+
+// currentStrategy는 chat에 저장된 추천 방식. 미설정이거나 조회 실패 시 기본 방식.
+func (t TeleBot) currentStrategy() Strategy {
+	name, err := t.stg.Strategy(t.owner)
+	if err != nil || name == "" {
+		name = defaultStrategy
+	}
+	st, _ := findStrategy(name)
+	return st
+}
+
+// seasonJob은 시즌이 주어지면 현재 시즌을 변경하고, 없으면 현재 시즌과 시즌별 완료 기록 버튼을 보낸다.
+// 시즌을 바꿔도 이전 시즌 기록은 삭제되지 않는다.
+func (t TeleBot) seasonJob(name string) {
+	mode, ok := t.currentMode()
+	if !ok {
+		return
+	}
+	if name != "" {
+		if err := t.stg.SaveSeason(t.owner, mode, name); err != nil {
+			t.SendMessage(fmt.Sprintf("시즌 변경 오류 발생. %s", err.Error()))
+			return
+		}
+		t.SendMessage(fmt.Sprintf("%s 시즌 변경 완료. 현재 시즌: %s\n이전 시즌 기록은 /season에서 조회", mode.Str(), seasonText(name)))
+		return
+	}
+
+	current, err := t.stg.Season(t.owner, mode)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+	seasons, err := t.stg.Seasons(t.owner, mode)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
 	}
 
+	t.SendMessage(fmt.Sprintf("%s 현재 시즌: %s\n시즌 변경은 /season [시즌]", mode.Str(), seasonText(current)))
+	opt := DecOptMsg{
+		Title: titleSeason,
+	}
+	nonce := newNonce()
+	pendings := make([]Pending, len(seasons))
+	for i, s := range seasons {
+		label := seasonText(s)
+		if s == current {
+			label = "✅ " + label
+		}
+		cb := callback{Action: actSeason, Mode: mode, Key: strconv.Itoa(i), Nonce: nonce}
+		opt.Rcmds = append(opt.Rcmds, s)
+		opt.Ids = append(opt.Ids, cb.data())
+		opt.Labels = append(opt.Labels, label)
+		pendings[i] = Pending{Kind: PendingSeason, Mode: mode, Id: pendingId(cb), Name: s}
+	}
+	t.savePending(pendings...)
+	t.sendOptions(&opt)
+}
+
+// chooseSeasonJob은 선택한 시즌의 완료 기록을 보낸다.
+func (t TeleBot) chooseSeasonJob(update *tgbotapi.Update, cb callback) {
+	p, ok := t.pending(PendingSeason, cb)
+	if !ok {
+		return
+	}
+	s := p.Name
+
+	doneLi, err := t.stg.AllInSeason(t.owner, cb.Mode, s)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+	t.SendMessage(seasonDoneText(cb.Mode, s, doneLi))
+}
+
+func seasonText(season string) string {
+	if season == "" {
+		return "시즌 미지정"
+	}
+	return season
+}
+
+// seasonDoneText는 시즌별 완료 기록 문구. ex)
+//
+//	[정규 모드] 14 완료 기록 2개
+//	- 덱1
+//	- 덱2
+func seasonDoneText(mode Mode, season string, doneLi []DoneDeck) string {
+	if len(doneLi) == 0 {
+		return fmt.Sprintf("[%s] %s 완료된 덱이 없습니다.", mode.Str(), seasonText(season))
+	}
+	lines := []string{fmt.Sprintf("[%s] %s 완료 기록 %d개", mode.Str(), seasonText(season), len(doneLi))}
+	for _, d := range doneLi {
+		lines = append(lines, "- "+d.Name)
+	}
+	return strings.Join(lines, "\n")
 }
 
 // func (t TeleBot) fixJob() {
@@ -178,7 +459,17 @@ func (t TeleBot) doneJob() {
 // 	}
 // }
 
-func (t TeleBot) restoreJob(update *tgbotapi.Update) {
+// restoreJob은 완료 목록에서 누른 덱의 완료 기록을 삭제한다. 같은 버튼은 한 번만 처리한다.
+func (t TeleBot) restoreJob(update *tgbotapi.Update, cb callback) {
+	p, ok, err := t.takePending(PendingDone, cb.Mode, pendingId(cb), time.Now())
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+	if !ok {
+		t.SendMessage("이미 처리했거나 만료된 버튼. /done으로 다시 조회 필요")
+		return
+	}
 
 	// Update the inline keyboard with the new checkbox state
 	newKeyboard := tgbotapi.NewInlineKeyboardMarkup(
@@ -193,38 +484,130 @@ func (t TeleBot) restoreJob(update *tgbotapi.Update) {
 		return
 	}
 
-	doneNum := update.CallbackQuery.Data
-	mode := t.stg.Mode()
-	t.stg.DeleteByName(mode, doneDeckMap[doneNum])
+	if p.Key != "" {
+		err = t.stg.Delete(t.owner, cb.Mode, p.Key)
+	} else {
+		err = t.stg.DeleteByName(t.owner, cb.Mode, p.Name)
+	}
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("완료 기록 삭제 오류 발생. %s", err.Error()))
+	}
 }
 
-func (t TeleBot) selectJob(update *tgbotapi.Update) {
+func (t TeleBot) selectJob(update *tgbotapi.Update, cb callback) {
 
-	// 여기서는 url 정보 한번 보내고
-	idx := update.CallbackQuery.Data
-	id, err := strconv.Atoi(idx)
+	// 여기서는 덱 구성, url 정보 한번 보내고
+	mode, key := cb.Mode, cb.Key
+	deck, err := t.dc.Composition(mode, key)
+	if errors.Is(err, ErrDeckNotInMeta) {
+		t.SendMessage("현재 메타에 없는 덱입니다. /update로 덱 갱신 필요")
+		return
+	}
 	if err != nil {
-		t.SendMessage("서버 오류 발생. 숫자형이 아닌 덱 id 사용")
+		t.SendMessage("Deck 정보 가져오기 오류. " + err.Error())
 		return
 	}
-	mode := t.stg.Mode()
-	url, err := t.dc.DeckBuilderUrl(mode, id)
+	url, err := t.dc.DeckBuilderUrl(mode, key)
 	if err != nil {
 		t.SendMessage("Deck url 가져오기 오류. " + err.Error())
+		return
 	}
-	t.SendMessage(url)
 
-	i, _ := strconv.Atoi(idx)
+	t.SendMessage(deckSummary(&deck) + "\n\n" + url)
+	t.savePending(Pending{Kind: PendingDeck, Mode: mode, Id: key, Key: deck.Key, Name: deck.Name})
+	if err := t.stg.SaveSelection(t.owner, mode, deck.Key, deck.Name); err != nil {
+		log.Printf("덱 선택 기록 실패. %s", err.Error())
+	}
 
 	// 완료버튼에 data 부터 덱명 담아서 보내야함.
 	t.sendOptions(&DecOptMsg{
 		Title: titleWhetherCompleted,
-		Rcmds: []string{candidateDeckMap[idx]},
-		Ids:   []int{i},
+		Rcmds: []string{deck.Name},
+		Ids:   []string{callback{Action: actComplete, Mode: mode, Key: key}.data()},
 	})
+	t.sendOptions(placementOptions(mode, key))
+}
+
+// placementOptions는 게임마다 누를 1~8등 버튼
+func placementOptions(mode Mode, key string) *DecOptMsg {
+	opt := DecOptMsg{
+		Title: titlePlacement,
+		Cols:  4,
+	}
+	for p := 1; p <= 8; p++ {
+		opt.Rcmds = append(opt.Rcmds, fmt.Sprintf("%d등", p))
+		opt.Ids = append(opt.Ids, callback{Action: actPlacement, Mode: mode, Key: key, Arg: strconv.Itoa(p)}.data())
+	}
+	return &opt
+}
+
+// placementJob은 게임 결과를 기록하고, 등수가 successPlace 이내면 덱을 완료 처리한다.
+func (t TeleBot) placementJob(update *tgbotapi.Update, cb callback) {
+	mode, key := cb.Mode, cb.Key
+	placement, err := strconv.Atoi(cb.Arg)
+	if err != nil || placement < 1 || placement > 8 || key == "" {
+		t.SendMessage("서버 오류 발생. 잘못된 등수 data")
+		return
+	}
+
+	dec, ok := t.pending(PendingDeck, cb)
+	if !ok {
+		return
+	}
+
+	err = t.stg.SaveAttempt(t.owner, mode, Attempt{Key: dec.Key, Name: dec.Name, Placement: placement, PlayedAt: time.Now()})
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("등수 기록 오류 발생. %s", err.Error()))
+		return
+	}
+	attempts, err := t.stg.Attempts(t.owner, mode)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+	t.SendMessage(attemptText(dec.Name, placement, deckAttempts(attempts, dec.Key)))
+
+	if !succeeded(placement, t.successPlace) {
+		return
+	}
+	if err := t.stg.Save(t.owner, mode, dec.Key, dec.Name); err != nil {
+		t.SendMessage(fmt.Sprintf("완료 기록 저장 오류 발생. %s", err.Error()))
+		return
+	}
+	t.SendMessage(fmt.Sprintf("%d등 이내 달성. %s 완료 처리", t.successPlace, dec.Name))
 }
 
-func (t TeleBot) completeJob(update *tgbotapi.Update) {
+// succeeded는 placement가 완료 조건(successPlace 이내)을 만족하는지 여부. successPlace가 0이면 자동 완료하지 않는다.
+func succeeded(placement int, successPlace int) bool {
+	return successPlace > 0 && placement <= successPlace
+}
+
+func deckAttempts(attempts []Attempt, key string) []Attempt {
+	var li []Attempt
+	for _, a := range attempts {
+		if a.Key == key {
+			li = append(li, a)
+		}
+	}
+	return li
+}
+
+// attemptText는 등수 기록 문구. ex)
+//
+//	덱1 3등 기록. 3번째 시도 (5등 → 4등 → 3등)
+func attemptText(name string, placement int, attempts []Attempt) string {
+	places := make([]string, len(attempts))
+	for i, a := range attempts {
+		places[i] = fmt.Sprintf("%d등", a.Placement)
+	}
+	return fmt.Sprintf("%s %d등 기록. %d번째 시도 (%s)", name, placement, len(attempts), strings.Join(places, " → "))
+}
+
+func (t TeleBot) completeJob(update *tgbotapi.Update, cb callback) {
+	dec, ok := t.pending(PendingDeck, cb)
+	if !ok {
+		return
+	}
 
 	// Update the inline keyboard with the new checkbox state
 	newKeyboard := tgbotapi.NewInlineKeyboardMarkup(
@@ -239,10 +622,9 @@ func (t TeleBot) completeJob(update *tgbotapi.Update) {
 		return
 	}
 
-	doneNum := update.CallbackQuery.Data
-
-	mode := t.stg.Mode()
-	t.stg.Save(mode, candidateDeckMap[doneNum])
+	if err := t.stg.Save(t.owner, cb.Mode, dec.Key, dec.Name); err != nil {
+		t.SendMessage(fmt.Sprintf("완료 기록 저장 오류 발생. %s", err.Error()))
+	}
 }
 
 func (t TeleBot) SendMessage(msg string) { // todo. private.
@@ -256,11 +638,24 @@ func (t TeleBot) sendOptions(optMsg *DecOptMsg) {
 
 	msg := tgbotapi.NewMessage(t.chatId, optMsg.Title)
 
-	buttons := make([][]tgbotapi.InlineKeyboardButton, len(optMsg.Rcmds))
+	cols := max(optMsg.Cols, 1)
+	buttons := make([][]tgbotapi.InlineKeyboardButton, 0, len(optMsg.Rcmds))
+	n := 0
 	for i := 0; i < len(optMsg.Rcmds); i++ {
-		buttons[i] = tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(optMsg.Rcmds[i], strconv.Itoa(optMsg.Ids[i])),
-		)
+		if optMsg.Ids[i] == "" { // callback data를 만들 수 없는 버튼
+			continue
+		}
+		label := optMsg.Rcmds[i]
+		if i < len(optMsg.Labels) {
+			label = optMsg.Labels[i]
+		}
+		button := tgbotapi.NewInlineKeyboardButtonData(label, optMsg.Ids[i])
+		if n%cols == 0 {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(button))
+		} else {
+			buttons[len(buttons)-1] = append(buttons[len(buttons)-1], button)
+		}
+		n++
 	}
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		buttons...,
@@ -273,28 +668,29 @@ func (t TeleBot) sendOptions(optMsg *DecOptMsg) {
 	}
 }
 
-func makeDecRcmd(decLi []string, doneLi []string) []DecOptMsg {
+func makeDecRcmd(mode Mode, st Strategy, decLi []Deck, doneLi []DoneDeck, lastAttempted map[string]time.Time) []DecOptMsg {
 
 	rtn := []DecOptMsg{}
 
-	m := make(map[string]bool)
-	for _, d := range doneLi {
-		m[d] = true
-	}
+	m := doneSet(decLi, doneLi)
 
 	selected := false
 	specialDec := make([]string, 0)
-	specailIdx := make([]int, 0)
-
-	for i := len(decLi) - 1; i >= 0; i-- {
-		if strings.HasPrefix(decLi[i], "[") && !m[decLi[i]] { // && !strings.Contains(decLi[i], "[상징]")
-			specialDec = append(specialDec, decLi[i])
-			specailIdx = append(specailIdx, i) // index 보정 필요 없음
-		} else if !selected && !m[decLi[i]] {
+	specailIdx := make([]string, 0)
+	specialLabel := make([]string, 0)
+
+	for _, i := range st.Order(decLi, lastAttempted) {
+		name := decLi[i].Name
+		if strings.HasPrefix(name, "[") && !m[i] { // && !strings.Contains(decLi[i], "[상징]")
+			specialDec = append(specialDec, name)
+			specailIdx = append(specailIdx, callback{Action: actSelect, Mode: mode, Key: decLi[i].Key}.data())
+			specialLabel = append(specialLabel, deckLabel(&decLi[i]))
+		} else if !selected && !m[i] {
 			rtn = append(rtn, DecOptMsg{
-				Title: titleNormalDeck,
-				Rcmds: []string{decLi[i]},
-				Ids:   []int{i}, // index 보정 필요 없음
+				Title:  titleNormalDeck,
+				Rcmds:  []string{name},
+				Ids:    []string{callback{Action: actSelect, Mode: mode, Key: decLi[i].Key}.data()},
+				Labels: []string{deckLabel(&decLi[i])},
 			})
 			selected = true
 		}
@@ -302,29 +698,115 @@ func makeDecRcmd(decLi []string, doneLi []string) []DecOptMsg {
 
 	if len(specialDec) > 0 {
 		rtn = append(rtn, DecOptMsg{
-			Title: titleSpecDeck,
-			Rcmds: specialDec,
-			Ids:   specailIdx,
+			Title:  titleSpecDeck,
+			Rcmds:  specialDec,
+			Ids:    specailIdx,
+			Labels: specialLabel,
 		})
 	}
 
 	return rtn
 
 }
-func makeDecDone(doneLi []string) DecOptMsg {
 
-	ids := make([]int, len(doneLi))
+// makeDecDone은 완료 목록 버튼. nonce는 목록마다 다른 id
+func makeDecDone(mode Mode, nonce string, doneLi []DoneDeck) DecOptMsg {
+
+	ids := make([]string, len(doneLi))
+	names := make([]string, len(doneLi))
 	for i := range ids {
-		ids[i] = i
+		ids[i] = callback{Action: actRestore, Mode: mode, Key: strconv.Itoa(i), Nonce: nonce}.data()
+		names[i] = doneLi[i].Name
 	}
 
 	return DecOptMsg{
 		Title: titleCompletionList,
-		Rcmds: doneLi,
+		Rcmds: names,
 		Ids:   ids,
 	}
 }
 
+// deckSummary는 덱 구성(챔피언/아이템, 시너지, 증강, 회전초밥)을 메시지 형식으로 만든다.
+func deckSummary(deck *Deck) string {
+	var sb strings.Builder
+	sb.WriteString(deck.Name)
+	if stat := deckStat(deck); stat != "" {
+		sb.WriteString("\n" + stat)
+	}
+
+	if len(deck.Units) > 0 {
+		sb.WriteString("\n\n[챔피언]")
+		for _, u := range deck.Units {
+			sb.WriteString("\n" + u.Name)
+			if u.Star > 0 {
+				sb.WriteString(" " + strings.Repeat("★", u.Star))
+			}
+			if len(u.Items) > 0 {
+				sb.WriteString(" - " + strings.Join(u.Items, ", "))
+			}
+		}
+	}
+
+	if len(deck.Traits) > 0 {
+		traits := make([]string, len(deck.Traits))
+		for i, tr := range deck.Traits {
+			traits[i] = fmt.Sprintf("%s %d", tr.Name, tr.Count)
+		}
+		sb.WriteString("\n\n[시너지] " + strings.Join(traits, ", "))
+	}
+	if len(deck.Augments) > 0 {
+		sb.WriteString("\n[증강] " + strings.Join(deck.Augments, ", "))
+	}
+	if len(deck.Carousel) > 0 {
+		sb.WriteString("\n[회전초밥] " + strings.Join(deck.Carousel, " > "))
+	}
+
+	return sb.String()
+}
+
+// deckStat은 티어와 통계를 한 줄로 만든다. ex) S티어 · 평균 3.92등 · top4 61.1% · 1등 17.2% · 픽률 8.1%
+func deckStat(deck *Deck) string {
+	parts := make([]string, 0, 5)
+	if deck.Tier != "" {
+		parts = append(parts, deck.Tier+"티어")
+	}
+	if deck.Stats.Plays > 0 || deck.Stats.AvgPlacement > 0 {
+		parts = append(parts,
+			fmt.Sprintf("평균 %.2f등", deck.Stats.AvgPlacement),
+			fmt.Sprintf("top4 %.1f%%", deck.Stats.Top4Rate*100),
+			fmt.Sprintf("1등 %.1f%%", deck.Stats.WinRate*100),
+			fmt.Sprintf("픽률 %.1f%%", deck.Stats.PickRate*100),
+		)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// ago는 경과 시간을 "3분 전" 형식으로 나타낸다.
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "방금 전"
+	case d < time.Hour:
+		return fmt.Sprintf("%d분 전", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d시간 전", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d일 전", int(d.Hours()/24))
+	}
+}
+
+// deckLabel은 추천 버튼 문구. 버튼 폭을 고려해 티어, 평균 등수, top4만 표시한다. ex) [S] 덱이름 (3.92등/61%)
+func deckLabel(deck *Deck) string {
+	label := deck.Name
+	if deck.Tier != "" {
+		label = fmt.Sprintf("[%s] %s", deck.Tier, label)
+	}
+	if deck.Stats.Plays > 0 || deck.Stats.AvgPlacement > 0 {
+		label += fmt.Sprintf(" (%.2f등/%.0f%%)", deck.Stats.AvgPlacement, deck.Stats.Top4Rate*100)
+	}
+	return label
+}
+
 /***************************************************************** DELETE *******************************************************************************************/
 
 /*
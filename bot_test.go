@@ -1,9 +1,20 @@
 package lolcheBot
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func TestBot(t *testing.T) {
@@ -45,7 +56,310 @@ func TestRun(t *testing.T) {
 		tele.sendOptions(&DecOptMsg{
 			Title: "HI",
 			Rcmds: []string{"덱1", "덱2"},
-			Ids:   []int{1, 2},
+			Ids:   []string{"1", "2"},
 		})
 	})
 }
+
+// telegramServer는 bot이 보낸 요청을 기록하는 Telegram Bot API
+type telegramServer struct {
+	mu       sync.Mutex
+	requests []telegramRequest
+}
+
+type telegramRequest struct {
+	method string
+	text   string
+	markup string
+}
+
+func (s *telegramServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if method == "getMe" {
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"bot","username":"bot"}}`)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, telegramRequest{method: method, text: r.Form.Get("text"), markup: r.Form.Get("reply_markup")})
+	s.mu.Unlock()
+	fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1}}}`)
+}
+
+// texts는 sendMessage로 보낸 메시지
+func (s *telegramServer) texts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rtn []string
+	for _, req := range s.requests {
+		if req.method == "sendMessage" {
+			rtn = append(rtn, req.text)
+		}
+	}
+	return rtn
+}
+
+// buttons는 마지막으로 보낸 버튼 메시지의 callback
+func (s *telegramServer) buttons(t *testing.T) []callback {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if s.requests[i].markup == "" {
+			continue
+		}
+		var markup tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal([]byte(s.requests[i].markup), &markup); err != nil {
+			t.Fatal(err)
+		}
+		var rtn []callback
+		for _, row := range markup.InlineKeyboard {
+			for _, button := range row {
+				cb, err := decodeCallback(*button.CallbackData)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rtn = append(rtn, cb)
+			}
+		}
+		return rtn
+	}
+	t.Fatal("no button sent")
+	return nil
+}
+
+// newTestBot은 telegramServer로 메시지를 보내는 chat 1의 TeleBot
+func newTestBot(t *testing.T, stg Stoage, dc DeckCrawler) (TeleBot, *telegramServer) {
+	t.Helper()
+
+	tg := &telegramServer{}
+	srv := httptest.NewServer(tg)
+	t.Cleanup(srv.Close)
+
+	api, err := tgbotapi.NewBotAPIWithClient("test", srv.URL+"/bot%s/%s", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return TeleBot{bot: api, chatId: 1, owner: 1, access: newAccess(1, nil, nil), stg: stg, dc: dc}, tg
+}
+
+// noAttemptStorage는 LastAttempted 조회가 실패하는 MemoryStorage
+type noAttemptStorage struct {
+	*MemoryStorage
+}
+
+func (s noAttemptStorage) LastAttempted(chatId int64, mode Mode) (map[string]time.Time, error) {
+	return nil, errors.New("db down")
+}
+
+func TestUpdateJob(t *testing.T) {
+
+	dc := NewScriptedCrawler().Script(MainMode,
+		ScriptResponse{Decks: ScriptDecks("덱1", "덱2")},
+		ScriptResponse{Err: errors.New("site down")},
+	)
+	bot, tg := newTestBot(t, noAttemptStorage{NewMemoryStorage()}, dc)
+
+	bot.updateJob()
+	if texts := tg.texts(); !slices.Equal(texts, []string{titleNormalDeck}) {
+		t.Errorf("failed LastAttempted should still recommend. got %q", texts)
+	}
+
+	bot.updateJob()
+	if texts := tg.texts(); len(texts) != 2 || texts[1] != "오류 발생 site down" {
+		t.Errorf("failed Meta should be reported. got %q", texts)
+	}
+}
+
+// callbackUpdate는 chat 1의 메시지 버튼을 누른 update
+func callbackUpdate(cb callback) *tgbotapi.Update {
+	return &tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		Data:    cb.data(),
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1}},
+	}}
+}
+
+// 같은 이름의 다른 덱 기록은 남기고, key 없는 기록만 이름으로 지운다.
+func TestRestoreJob(t *testing.T) {
+
+	stg := NewMemoryStorage()
+	stg.Save(1, MainMode, "k1", "덱1")
+	stg.Save(1, MainMode, "k2", "덱1")
+	stg.Save(1, MainMode, "", "덱2")
+	bot, _ := newTestBot(t, stg, NewScriptedCrawler())
+
+	keyed := callback{Action: actRestore, Mode: MainMode, Key: "0", Nonce: "a"}
+	named := callback{Action: actRestore, Mode: MainMode, Key: "1", Nonce: "a"}
+	bot.savePending(
+		Pending{Kind: PendingDone, Mode: MainMode, Id: pendingId(keyed), Key: "k1", Name: "덱1"},
+		Pending{Kind: PendingDone, Mode: MainMode, Id: pendingId(named), Name: "덱2"},
+	)
+
+	bot.restoreJob(callbackUpdate(keyed), keyed)
+	bot.restoreJob(callbackUpdate(named), named)
+
+	if done, _ := stg.All(1, MainMode); !slices.Equal(done, []DoneDeck{{Key: "k2", Name: "덱1"}}) {
+		t.Errorf("unexpected records %v", done)
+	}
+}
+
+func TestDeckSummary(t *testing.T) {
+
+	deck := Deck{
+		Name: "빌지워터 미스 포츈",
+		Units: []Unit{
+			{Name: "미스 포츈", Cost: 4, Star: 2, Items: []string{"무한의 대검", "거인 학살자"}},
+			{Name: "그레이브즈", Cost: 1, Star: 2},
+		},
+		Traits:   []Trait{{Name: "빌지워터", Count: 5}, {Name: "사수", Count: 2}},
+		Augments: []string{"빌지워터 문장"},
+		Carousel: []string{"무한의 대검", "거인 학살자"},
+	}
+
+	want := `빌지워터 미스 포츈
+
+[챔피언]
+미스 포츈 ★★ - 무한의 대검, 거인 학살자
+그레이브즈 ★★
+
+[시너지] 빌지워터 5, 사수 2
+[증강] 빌지워터 문장
+[회전초밥] 무한의 대검 > 거인 학살자`
+
+	if got := deckSummary(&deck); got != want {
+		t.Errorf("unexpected summary\n%s", got)
+	}
+
+	if got := deckSummary(&Deck{Name: "덱"}); got != "덱" {
+		t.Errorf("deck without composition should only show name. got %s", got)
+	}
+}
+
+func TestDeckLabel(t *testing.T) {
+
+	deck := Deck{
+		Name:  "빌지워터 미스 포츈",
+		Tier:  "S",
+		Stats: DeckStats{AvgPlacement: 3.92, Top4Rate: 0.611, WinRate: 0.172, PickRate: 0.081, Plays: 32400},
+	}
+
+	if got := deckLabel(&deck); got != "[S] 빌지워터 미스 포츈 (3.92등/61%)" {
+		t.Errorf("unexpected label %s", got)
+	}
+	if got := deckStat(&deck); got != "S티어 · 평균 3.92등 · top4 61.1% · 1등 17.2% · 픽률 8.1%" {
+		t.Errorf("unexpected stat %s", got)
+	}
+	if got := deckLabel(&Deck{Name: "덱"}); got != "덱" {
+		t.Errorf("deck without stats should only show name. got %s", got)
+	}
+
+	rcmd := makeDecRcmd(MainMode, bottomUp{}, []Deck{deck, {Name: "[증강] 덱", Tier: "B"}}, nil, nil)
+	if len(rcmd) != 2 || rcmd[0].Rcmds[0] != "빌지워터 미스 포츈" || rcmd[0].Labels[0] != deckLabel(&deck) || rcmd[1].Labels[0] != "[B] [증강] 덱" {
+		t.Errorf("recommendation should keep deck name and show label. got %+v", rcmd)
+	}
+}
+
+func TestAgo(t *testing.T) {
+
+	tests := map[time.Duration]string{
+		30 * time.Second:             "방금 전",
+		5 * time.Minute:              "5분 전",
+		3*time.Hour + 59*time.Minute: "3시간 전",
+		50 * time.Hour:               "2일 전",
+	}
+	for d, want := range tests {
+		if got := ago(d); got != want {
+			t.Errorf("ago(%s). expected %s got %s", d, want, got)
+		}
+	}
+}
+
+func TestSeasonDoneText(t *testing.T) {
+
+	doneLi := []DoneDeck{{Key: "k1", Name: "덱1"}, {Name: "덱2"}}
+	if got := seasonDoneText(MainMode, "14", doneLi); got != "[정규 모드] 14 완료 기록 2개\n- 덱1\n- 덱2" {
+		t.Errorf("unexpected text\n%s", got)
+	}
+	if got := seasonDoneText(PbeMode, "", nil); got != "[pbe 모드] 시즌 미지정 완료된 덱이 없습니다." {
+		t.Errorf("unexpected text\n%s", got)
+	}
+}
+
+func TestPlacementOptions(t *testing.T) {
+
+	key := "0932c429e79980626aeb3253f102a739ecf9fc6e"
+	opt := placementOptions(MainMode, key)
+	if len(opt.Ids) != 8 || opt.Rcmds[7] != "8등" || opt.Cols != 4 {
+		t.Errorf("unexpected placement options %+v", opt)
+	}
+	cb, err := decodeCallback(opt.Ids[2])
+	if err != nil || cb != (callback{Action: actPlacement, Mode: MainMode, Key: key, Arg: "3"}) {
+		t.Errorf("unexpected placement callback %+v %v", cb, err)
+	}
+}
+
+func TestSucceeded(t *testing.T) {
+
+	tests := []struct {
+		placement, successPlace int
+		want                    bool
+	}{
+		{1, 1, true},
+		{2, 1, false},
+		{4, 4, true},
+		{5, 4, false},
+		{1, 0, false},
+	}
+	for _, tt := range tests {
+		if got := succeeded(tt.placement, tt.successPlace); got != tt.want {
+			t.Errorf("succeeded(%d, %d). expected %v", tt.placement, tt.successPlace, tt.want)
+		}
+	}
+}
+
+func TestAttemptText(t *testing.T) {
+
+	attempts := []Attempt{
+		{Key: "k1", Name: "덱1", Placement: 5},
+		{Key: "k2", Name: "덱2", Placement: 1},
+		{Key: "k1", Name: "덱1", Placement: 3},
+	}
+	if got := attemptText("덱1", 3, deckAttempts(attempts, "k1")); got != "덱1 3등 기록. 2번째 시도 (5등 → 3등)" {
+		t.Errorf("unexpected text\n%s", got)
+	}
+}
+
+func TestForUpdate(t *testing.T) {
+
+	group := &tgbotapi.Chat{ID: -100, Type: "group"}
+	private := &tgbotapi.Chat{ID: 7, Type: "private"}
+	user := &tgbotapi.User{ID: 7}
+	message := func(chat *tgbotapi.Chat) *tgbotapi.Update {
+		return &tgbotapi.Update{Message: &tgbotapi.Message{Chat: chat, From: user}}
+	}
+
+	tests := []struct {
+		name      string
+		perUser   bool
+		update    *tgbotapi.Update
+		wantChat  int64
+		wantOwner int64
+	}{
+		{"group", false, message(group), -100, -100},
+		{"group per user", true, message(group), -100, 7},
+		{"private per user", true, message(private), 7, 7},
+		{"button per user", true, &tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: user, Message: &tgbotapi.Message{Chat: group}}}, -100, 7},
+		{"no chat", true, &tgbotapi.Update{}, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := TeleBot{chatId: 1, owner: 1, perUser: tt.perUser}.forUpdate(tt.update)
+			if bot.chatId != tt.wantChat || bot.owner != tt.wantOwner {
+				t.Errorf("expected chat %d owner %d. got chat %d owner %d", tt.wantChat, tt.wantOwner, bot.chatId, bot.owner)
+			}
+		})
+	}
+}
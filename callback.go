@@ -0,0 +1,145 @@
+package lolcheBot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// callbackVersion은 callback data 형식 version. 형식이 바뀌면 올려서 이전에 보낸 버튼을 만료 처리한다.
+const callbackVersion = "1"
+
+// callbackDataLimit은 telegram 버튼 callback data 최대 byte 수
+const callbackDataLimit = 64
+
+const callbackSep = "|"
+
+var (
+	errCallbackExpired   = errors.New("만료된 버튼")
+	errCallbackUnknown   = errors.New("등록되지 않은 버튼")
+	errCallbackForbidden = errors.New("관리자 전용 버튼")
+)
+
+type callbackAction string
+
+const (
+	actSelect    callbackAction = "s"  // 추천 덱 선택. Key: 덱 key
+	actComplete  callbackAction = "c"  // 완료 처리. Key: 덱 key
+	actPlacement callbackAction = "p"  // 등수 기록. Key: 덱 key, Arg: 등수
+	actRestore   callbackAction = "r"  // 완료 취소. Key: 완료 목록 index
+	actStrategy  callbackAction = "st" // 추천 방식 선택. Key: 추천 방식 index
+	actSeason    callbackAction = "se" // 시즌 기록 조회. Key: 시즌 목록 index
+	actMode      callbackAction = "m"  // 모드 선택. Key: 모드 이름
+	actReset     callbackAction = "rs" // 기록 삭제 확인. Arg: confirm 또는 cancel, Nonce: 삭제 요청 id
+	actUndoReset callbackAction = "u"  // 기록 삭제 되돌리기. Nonce: 되돌리기 id
+)
+
+// callback은 버튼 callback data. "version|action|mode|key|arg|nonce" 형식으로 encode한다.
+// Mode는 버튼을 보낼 때의 모드라서, 이후 모드를 바꿔도 버튼은 보낸 모드 기준으로 처리된다.
+type callback struct {
+	Action callbackAction
+	Mode   Mode
+	Key    string
+	Arg    string
+	Nonce  string
+}
+
+func (c callback) encode() (string, error) {
+	fields := []string{callbackVersion, string(c.Action), string(c.Mode), c.Key, c.Arg, c.Nonce}
+	for _, f := range fields {
+		if strings.Contains(f, callbackSep) {
+			return "", fmt.Errorf("callback data에 %q 포함 불가. %q", callbackSep, f)
+		}
+	}
+	data := strings.Join(fields, callbackSep)
+	if len(data) > callbackDataLimit {
+		return "", fmt.Errorf("callback data %dbyte 초과. %s", callbackDataLimit, data)
+	}
+	return data, nil
+}
+
+// data는 버튼에 넣을 callback data. encode할 수 없으면 빈 문자열이며 sendOptions가 해당 버튼을 제외한다.
+func (c callback) data() string {
+	data, err := c.encode()
+	if err != nil {
+		log.Printf("버튼 생성 실패. %s", err.Error())
+		return ""
+	}
+	return data
+}
+
+// decodeCallback은 callback data를 해석한다. 다른 version이거나 형식이 다르면(이전 형식 버튼) errCallbackExpired
+func decodeCallback(data string) (callback, error) {
+	fields := strings.Split(data, callbackSep)
+	if len(fields) != 6 || fields[0] != callbackVersion || fields[1] == "" {
+		return callback{}, errCallbackExpired
+	}
+	return callback{
+		Action: callbackAction(fields[1]),
+		Mode:   Mode(fields[2]),
+		Key:    fields[3],
+		Arg:    fields[4],
+		Nonce:  fields[5],
+	}, nil
+}
+
+type callbackHandler func(update *tgbotapi.Update, cb callback)
+
+// callbackRouter는 callback data의 action별로 handler를 호출한다.
+type callbackRouter struct {
+	handlers map[callbackAction]callbackHandler
+	admin    map[callbackAction]bool // 관리자만 누를 수 있는 action
+}
+
+func newCallbackRouter() *callbackRouter {
+	return &callbackRouter{
+		handlers: make(map[callbackAction]callbackHandler),
+		admin:    make(map[callbackAction]bool),
+	}
+}
+
+func (r *callbackRouter) handle(action callbackAction, h callbackHandler) {
+	r.handlers[action] = h
+}
+
+// handleAdmin은 관리자만 누를 수 있는 action의 handler를 등록한다.
+func (r *callbackRouter) handleAdmin(action callbackAction, h callbackHandler) {
+	r.handle(action, h)
+	r.admin[action] = true
+}
+
+// route는 update의 callback data를 해석해 handler를 호출한다. admin은 버튼을 누른 사용자가 관리자인지 여부.
+// 호출하지 못하면 errCallbackExpired, errCallbackUnknown 또는 errCallbackForbidden
+func (r *callbackRouter) route(update *tgbotapi.Update, admin bool) error {
+	cb, err := decodeCallback(update.CallbackQuery.Data)
+	if err != nil {
+		return err
+	}
+	h, ok := r.handlers[cb.Action]
+	if !ok {
+		return fmt.Errorf("%w. %s", errCallbackUnknown, cb.Action)
+	}
+	if r.admin[cb.Action] && !admin {
+		return fmt.Errorf("%w. %s", errCallbackForbidden, cb.Action)
+	}
+	h(update, cb)
+	return nil
+}
+
+// callbacks는 bot이 보내는 버튼의 handler를 등록한 router
+func (t TeleBot) callbacks() *callbackRouter {
+	r := newCallbackRouter()
+	r.handle(actSelect, t.selectJob)
+	r.handle(actComplete, t.completeJob)
+	r.handle(actPlacement, t.placementJob)
+	r.handle(actRestore, t.restoreJob)
+	r.handle(actStrategy, t.chooseStrategyJob)
+	r.handle(actSeason, t.chooseSeasonJob)
+	r.handleAdmin(actMode, t.chooseModeJob)
+	r.handleAdmin(actReset, t.confirmResetJob)
+	r.handleAdmin(actUndoReset, t.undoResetJob)
+	return r
+}
@@ -0,0 +1,95 @@
+package lolcheBot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestCallbackRoundTrip(t *testing.T) {
+
+	want := callback{Action: actReset, Mode: PbeMode, Arg: "confirm", Nonce: "12"}
+	data, err := want.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeCallback(data)
+	if err != nil || got != want {
+		t.Errorf("round trip failed. %q -> %+v %v", data, got, err)
+	}
+}
+
+func TestCallbackEncodeLimit(t *testing.T) {
+
+	// 가장 긴 실제 payload: 16자 모드와 40자 key의 등수 기록 버튼
+	longest := callback{Action: actPlacement, Mode: Mode(strings.Repeat("m", 16)), Key: strings.Repeat("k", 40), Arg: "8"}
+	if _, err := longest.encode(); err != nil {
+		t.Errorf("longest payload should fit. %v", err)
+	}
+
+	tooLong := callback{Action: actSelect, Mode: MainMode, Key: strings.Repeat("k", 64)}
+	if _, err := tooLong.encode(); err == nil {
+		t.Error("payload over 64 bytes should fail")
+	}
+	if tooLong.data() != "" {
+		t.Error("data of invalid payload should be empty")
+	}
+
+	if _, err := (callback{Action: actSelect, Mode: MainMode, Key: "a|b"}).encode(); err == nil {
+		t.Error("field with separator should fail")
+	}
+}
+
+func TestDecodeCallbackExpired(t *testing.T) {
+
+	for _, data := range []string{
+		"deck-key",            // 이전 형식: 덱 key
+		"1:deck-key",          // 이전 형식: 등수 기록
+		"0|s|main|deck-key||", // 다른 version
+		"1||main|deck-key||",  // action 없음
+		"",
+	} {
+		if _, err := decodeCallback(data); !errors.Is(err, errCallbackExpired) {
+			t.Errorf("%q should be expired. got %v", data, err)
+		}
+	}
+}
+
+func TestCallbackRouter(t *testing.T) {
+
+	r := newCallbackRouter()
+	var got callback
+	r.handle(actComplete, func(_ *tgbotapi.Update, cb callback) { got = cb })
+
+	update := func(cb callback) *tgbotapi.Update {
+		return &tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: cb.data()}}
+	}
+
+	want := callback{Action: actComplete, Mode: MainMode, Key: "deck-key"}
+	if err := r.route(update(want), false); err != nil || got != want {
+		t.Errorf("handler should be called with %+v. got %+v %v", want, got, err)
+	}
+	if err := r.route(update(callback{Action: actSelect, Mode: MainMode}), false); !errors.Is(err, errCallbackUnknown) {
+		t.Errorf("unregistered action should fail. got %v", err)
+	}
+	if err := r.route(&tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: "deck-key"}}, false); !errors.Is(err, errCallbackExpired) {
+		t.Errorf("old button should be expired. got %v", err)
+	}
+}
+
+func TestCallbackRouterAdmin(t *testing.T) {
+
+	r := newCallbackRouter()
+	called := false
+	r.handleAdmin(actReset, func(*tgbotapi.Update, callback) { called = true })
+
+	update := &tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Data: callback{Action: actReset, Mode: MainMode, Arg: "confirm"}.data()}}
+	if err := r.route(update, false); !errors.Is(err, errCallbackForbidden) || called {
+		t.Errorf("member should not press admin button. got %v called=%v", err, called)
+	}
+	if err := r.route(update, true); err != nil || !called {
+		t.Errorf("admin should press admin button. got %v called=%v", err, called)
+	}
+}
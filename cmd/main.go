@@ -1,10 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"lolcheBot"
 	"lolcheBot/config"
 	"lolcheBot/crawl"
 	"lolcheBot/db"
+	"os"
 )
 
 func main() {
@@ -13,7 +15,23 @@ func main() {
 		panic(err)
 	}
 
-	crawler := crawl.New()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(conf, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fetcher, err := crawl.NewHttpFetcher(conf.FetcherConfig())
+	if err != nil {
+		panic(err)
+	}
+	crawlerConf, err := conf.CrawlerConfig()
+	if err != nil {
+		panic(err)
+	}
+	crawler := crawl.NewCrawler(crawlerConf, fetcher)
 	db, err := db.NewStorage(conf.StorageConfig())
 	if err != nil {
 		panic(err)
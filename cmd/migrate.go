@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"lolcheBot/config"
+	"lolcheBot/db"
+	"time"
+)
+
+// runMigrate는 migrate subcommand. ex)
+//
+//	lolchebot migrate status   # 현재 schema version과 migration별 적용 여부 출력
+//	lolchebot migrate up       # 적용되지 않은 migration 적용
+func runMigrate(conf *config.Config, args []string) error {
+	cmd := "status"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	m, err := db.NewMigrator(conf.StorageConfig())
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case "status":
+		current, li, err := m.Status()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("schema version %d (최신 %d)\n", current, db.LatestVersion())
+		if current > db.LatestVersion() {
+			fmt.Println("db schema가 이 버전보다 높아 실행할 수 없습니다.")
+		}
+		for _, mig := range li {
+			if mig.Applied() {
+				fmt.Printf("  [x] %d %s (%s)\n", mig.Version, mig.Name, mig.AppliedAt.Format(time.DateTime))
+			} else {
+				fmt.Printf("  [ ] %d %s\n", mig.Version, mig.Name)
+			}
+		}
+	case "up":
+		applied, err := m.Up()
+		for _, mig := range applied {
+			fmt.Printf("applied %d %s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("적용할 migration 없음")
+		}
+	default:
+		return fmt.Errorf("알 수 없는 migrate 명령 %s. (status | up)", cmd)
+	}
+	return nil
+}
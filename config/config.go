@@ -3,8 +3,10 @@ package config
 import (
 	_ "embed"
 	t "lolcheBot"
+	"lolcheBot/crawl"
 	"lolcheBot/db"
 	"strconv"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,17 +16,41 @@ var configByte []byte
 
 type Config struct {
 	TeleBot struct {
-		Token  string `yaml:"token"`
-		ChatId string `yaml:"chatId"`
+		Token   string  `yaml:"token"`
+		ChatId  string  `yaml:"chatId"`  // meta 변경 알림을 받을 chat. chat 구분 도입 전 기록도 이 chat으로 옮겨진다.
+		PerUser bool    `yaml:"perUser"` // 그룹 chat에서 사용자별로 기록. default false
+		Allow   []int64 `yaml:"allow"`   // 사용할 수 있는 user id, chat id. chatId는 항상 허용
+		Admins  []int64 `yaml:"admins"`  // 관리자 user id. 없으면 chatId가 관리자
+
+		WatchInterval    string `yaml:"watchInterval"`    // meta 변경 확인 주기. 0이면 미사용. default 30m
+		SuccessPlacement *int   `yaml:"successPlacement"` // 이 등수 이내면 덱 자동 완료. 0이면 미사용. default 4
 	} `yaml:"telegram"`
 
 	Db struct {
+		Driver   string `yaml:"driver"` // mysql(default) 또는 sqlite
+		Path     string `yaml:"path"`   // sqlite 파일 경로
 		User     string `yaml:"user"`
 		Password string `yaml:"pw"`
 		IP       string `yaml:"ip"`
 		Port     string `yaml:"port"`
 		Scheme   string `yaml:"scheme"`
 	} `yaml:"db"`
+
+	Crawler struct {
+		CacheTtl     string `yaml:"cacheTtl"`     // ex) 5m. default 5m
+		RefreshAhead string `yaml:"refreshAhead"` // 만료 전 백그라운드 갱신 시작 시점. default 1m
+		SnapshotDir  string `yaml:"snapshotDir"`  // 마지막 크롤링 결과 저장 위치. default data/snapshot
+
+		Modes map[string]string `yaml:"modes"` // 모드 이름별 meta 페이지 url. main, pbe는 기본 url 사용
+
+		Timeout     string            `yaml:"timeout"`     // 요청 1회 제한 시간. default 15s
+		MaxRetries  *int              `yaml:"maxRetries"`  // 5xx, 429, 네트워크 오류 시 재시도 횟수. default 3
+		BaseDelay   string            `yaml:"baseDelay"`   // 첫 재시도 대기 시간. default 500ms
+		MaxDelay    string            `yaml:"maxDelay"`    // 재시도 대기 시간 상한. default 10s
+		MaxBodySize int64             `yaml:"maxBodySize"` // 응답 최대 크기(byte). default 10MB
+		Headers     map[string]string `yaml:"headers"`
+		Proxy       string            `yaml:"proxy"`
+	} `yaml:"crawler"`
 }
 
 func NewConfig() (*Config, error) {
@@ -38,17 +64,81 @@ func NewConfig() (*Config, error) {
 }
 
 func (c Config) Telebot() *t.TeleBotConfig {
-	chatId, _ := strconv.ParseInt(c.TeleBot.ChatId, 10, 64)
-	return t.NewTeleBotConfig(c.TeleBot.Token, chatId)
+	successPlace := 4
+	if c.TeleBot.SuccessPlacement != nil {
+		successPlace = *c.TeleBot.SuccessPlacement
+	}
+	return t.NewTeleBotConfig(c.TeleBot.Token, c.chatId(), c.TeleBot.PerUser, c.TeleBot.Allow, c.TeleBot.Admins, duration(c.TeleBot.WatchInterval, 30*time.Minute), successPlace)
 }
 
 func (c Config) StorageConfig() *db.StorageConfig {
+	if c.Db.Driver == db.DriverSqlite {
+		return db.NewSqliteStorageConfig(c.Db.Path).WithChatId(c.chatId())
+	}
 	return db.NewStorageConfig(
 		c.Db.User,
 		c.Db.Password,
 		c.Db.IP,
 		c.Db.Port,
 		c.Db.Scheme,
+	).WithChatId(c.chatId())
+
+}
+
+func (c Config) chatId() int64 {
+	chatId, _ := strconv.ParseInt(c.TeleBot.ChatId, 10, 64)
+	return chatId
+}
+
+func (c Config) CrawlerConfig() (*crawl.CrawlerConfig, error) {
+	snapshotDir := c.Crawler.SnapshotDir
+	if snapshotDir == "" {
+		snapshotDir = "data/snapshot"
+	}
+
+	urls := make(map[t.Mode]string, len(c.Crawler.Modes))
+	for name, url := range c.Crawler.Modes {
+		mode, err := t.ParseMode(name)
+		if err != nil {
+			return nil, err
+		}
+		urls[mode] = url
+	}
+
+	return crawl.NewCrawlerConfig(
+		duration(c.Crawler.CacheTtl, 5*time.Minute),
+		duration(c.Crawler.RefreshAhead, time.Minute),
+		snapshotDir,
+		urls,
+	), nil
+}
+
+func (c Config) FetcherConfig() *crawl.FetcherConfig {
+	maxRetries := 3
+	if c.Crawler.MaxRetries != nil {
+		maxRetries = *c.Crawler.MaxRetries
+	}
+	maxBodySize := c.Crawler.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = 10 << 20
+	}
+
+	return crawl.NewFetcherConfig(
+		duration(c.Crawler.Timeout, 15*time.Second),
+		maxRetries,
+		duration(c.Crawler.BaseDelay, 500*time.Millisecond),
+		duration(c.Crawler.MaxDelay, 10*time.Second),
+		maxBodySize,
+		c.Crawler.Headers,
+		c.Crawler.Proxy,
 	)
+}
 
+// duration은 설정값을 time.Duration으로 변환한다. 비어있거나 잘못된 값이면 def 사용
+func duration(s string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return def
+	}
+	return d
 }
@@ -0,0 +1,104 @@
+package crawl
+
+import (
+	"lolcheBot"
+	"sync"
+	"time"
+)
+
+// metaCache는 모드별 크롤링 결과를 ttl 동안 보관한다.
+//   - 만료 refreshAhead 전부터 조회가 오면 백그라운드에서 미리 갱신한다.
+//   - 만료되었더라도 갱신이 진행 중이면 기다리지 않고 기존(stale) 결과를 돌려준다.
+//   - 같은 모드에 대한 동시 크롤링은 하나로 합쳐진다(singleflight).
+type metaCache struct {
+	mu           sync.Mutex
+	ttl          time.Duration
+	refreshAhead time.Duration
+	fetch        func(mode lolcheBot.Mode) ([]DeckMeta, error)
+	now          func() time.Time
+
+	entries  map[lolcheBot.Mode]cacheEntry
+	inflight map[lolcheBot.Mode]*fetchCall
+}
+
+type cacheEntry struct {
+	decks     []DeckMeta
+	fetchedAt time.Time
+}
+
+// fetchCall은 진행 중인 크롤링. done이 닫힌 후에 decks, err를 읽는다.
+type fetchCall struct {
+	done  chan struct{}
+	decks []DeckMeta
+	err   error
+}
+
+func newMetaCache(ttl time.Duration, refreshAhead time.Duration, fetch func(mode lolcheBot.Mode) ([]DeckMeta, error)) *metaCache {
+	return &metaCache{
+		ttl:          ttl,
+		refreshAhead: refreshAhead,
+		fetch:        fetch,
+		now:          time.Now,
+		entries:      make(map[lolcheBot.Mode]cacheEntry),
+		inflight:     make(map[lolcheBot.Mode]*fetchCall),
+	}
+}
+
+// Get은 캐시된 결과를 반환한다. 캐시가 없거나, 만료되었는데 진행 중인 갱신이 없으면 크롤링이 끝날 때까지 기다린다.
+func (c *metaCache) Get(mode lolcheBot.Mode) ([]DeckMeta, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[mode]; ok {
+		age := c.now().Sub(e.fetchedAt)
+		if age < c.ttl || c.inflight[mode] != nil {
+			if age >= c.ttl-c.refreshAhead {
+				c.start(mode)
+			}
+			c.mu.Unlock()
+			return e.decks, nil
+		}
+	}
+	call := c.start(mode)
+	c.mu.Unlock()
+
+	<-call.done
+	return call.decks, call.err
+}
+
+// Refresh는 캐시와 관계없이 새로 크롤링한다. 이미 진행 중인 크롤링이 있으면 그 결과를 기다린다.
+func (c *metaCache) Refresh(mode lolcheBot.Mode) ([]DeckMeta, error) {
+	c.mu.Lock()
+	call := c.start(mode)
+	c.mu.Unlock()
+
+	<-call.done
+	return call.decks, call.err
+}
+
+// start는 mode의 크롤링을 시작하거나 진행 중인 크롤링을 반환한다. c.mu를 잡은 상태에서 호출해야 한다.
+func (c *metaCache) start(mode lolcheBot.Mode) *fetchCall {
+	if call, ok := c.inflight[mode]; ok {
+		return call
+	}
+
+	call := &fetchCall{done: make(chan struct{})}
+	c.inflight[mode] = call
+
+	go func() {
+		decks, err := c.fetch(mode)
+
+		c.mu.Lock()
+		if err == nil {
+			c.entries[mode] = cacheEntry{
+				decks:     decks,
+				fetchedAt: c.now(),
+			}
+		}
+		delete(c.inflight, mode)
+		c.mu.Unlock()
+
+		call.decks, call.err = decks, err
+		close(call.done)
+	}()
+
+	return call
+}
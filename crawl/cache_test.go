@@ -0,0 +1,201 @@
+package crawl
+
+import (
+	"errors"
+	"lolcheBot"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// go test -race ./crawl -run MetaCache
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Add(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// fakeFetch는 호출마다 "v{n}" 이름의 덱 하나를 돌려준다. gate가 있으면 값을 받을 때까지 대기한다.
+type fakeFetch struct {
+	calls atomic.Int32
+	gate  chan struct{}
+	err   error
+}
+
+func (f *fakeFetch) fetch(mode lolcheBot.Mode) ([]DeckMeta, error) {
+	n := f.calls.Add(1)
+	if f.gate != nil {
+		<-f.gate
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []DeckMeta{{Name: "v" + string(rune('0'+n))}}, nil
+}
+
+func newTestCache(f *fakeFetch) (*metaCache, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newMetaCache(5*time.Minute, time.Minute, f.fetch)
+	c.now = clock.Now
+	return c, clock
+}
+
+func mustGet(t *testing.T, c *metaCache, want string) {
+	t.Helper()
+	decks, err := c.Get(lolcheBot.MainMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decks[0].Name != want {
+		t.Errorf("expected %s. got %s", want, decks[0].Name)
+	}
+}
+
+// waitIdle은 백그라운드 갱신이 끝날 때까지 기다린다.
+func waitIdle(t *testing.T, c *metaCache) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		c.mu.Lock()
+		n := len(c.inflight)
+		c.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("background refresh not finished")
+}
+
+func TestMetaCacheTtl(t *testing.T) {
+	f := &fakeFetch{}
+	c, clock := newTestCache(f)
+
+	mustGet(t, c, "v1")
+	clock.Add(3 * time.Minute)
+	mustGet(t, c, "v1")
+	if f.calls.Load() != 1 {
+		t.Errorf("fresh entry should not be crawled again. calls %d", f.calls.Load())
+	}
+
+	clock.Add(3 * time.Minute)
+	mustGet(t, c, "v2")
+	if f.calls.Load() != 2 {
+		t.Errorf("expired entry should be crawled. calls %d", f.calls.Load())
+	}
+
+	if _, err := c.Get(lolcheBot.PbeMode); err != nil || f.calls.Load() != 3 {
+		t.Errorf("modes should be cached separately. calls %d %v", f.calls.Load(), err)
+	}
+}
+
+func TestMetaCacheRefreshAhead(t *testing.T) {
+	f := &fakeFetch{}
+	c, clock := newTestCache(f)
+
+	mustGet(t, c, "v1")
+	clock.Add(4*time.Minute + 30*time.Second)
+
+	mustGet(t, c, "v1") // 갱신 구간. 기존 값을 바로 돌려주고 백그라운드 갱신
+	waitIdle(t, c)
+	if f.calls.Load() != 2 {
+		t.Errorf("background refresh expected. calls %d", f.calls.Load())
+	}
+
+	clock.Add(4 * time.Minute) // 첫 조회 기준으로는 만료지만 갱신된 값은 유효
+	mustGet(t, c, "v2")
+	if f.calls.Load() != 2 {
+		t.Errorf("refreshed entry should be fresh. calls %d", f.calls.Load())
+	}
+}
+
+func TestMetaCacheServeStaleWhileRefreshing(t *testing.T) {
+	f := &fakeFetch{}
+	c, clock := newTestCache(f)
+	mustGet(t, c, "v1")
+
+	f.gate = make(chan struct{})
+	clock.Add(4*time.Minute + 30*time.Second)
+	mustGet(t, c, "v1") // 백그라운드 갱신 시작. gate에서 대기 중
+
+	clock.Add(time.Hour)
+	mustGet(t, c, "v1") // 만료되었지만 갱신 중이므로 stale 값
+
+	close(f.gate)
+	waitIdle(t, c)
+	mustGet(t, c, "v2")
+	if f.calls.Load() != 2 {
+		t.Errorf("only one refresh expected. calls %d", f.calls.Load())
+	}
+}
+
+func TestMetaCacheSingleflight(t *testing.T) {
+	f := &fakeFetch{gate: make(chan struct{})}
+	c, _ := newTestCache(f)
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var decks []DeckMeta
+			var err error
+			if i%2 == 0 {
+				decks, err = c.Get(lolcheBot.MainMode)
+			} else {
+				decks, err = c.Refresh(lolcheBot.MainMode)
+			}
+			if err == nil {
+				results[i] = decks[0].Name
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(f.gate)
+	wg.Wait()
+
+	if f.calls.Load() != 1 {
+		t.Errorf("concurrent crawls should be collapsed. calls %d", f.calls.Load())
+	}
+	for i, r := range results {
+		if r != "v1" {
+			t.Errorf("caller %d got %q", i, r)
+		}
+	}
+}
+
+func TestMetaCacheError(t *testing.T) {
+	errSite := errors.New("site down")
+	f := &fakeFetch{err: errSite}
+	c, clock := newTestCache(f)
+
+	if _, err := c.Get(lolcheBot.MainMode); !errors.Is(err, errSite) {
+		t.Errorf("expected crawl error. got %v", err)
+	}
+	if _, err := c.Get(lolcheBot.MainMode); err == nil || f.calls.Load() != 2 {
+		t.Errorf("failure should not be cached. calls %d", f.calls.Load())
+	}
+
+	f.err = nil
+	mustGet(t, c, "v3")
+
+	f.err = errSite
+	clock.Add(4*time.Minute + 30*time.Second)
+	mustGet(t, c, "v3") // 백그라운드 갱신 실패해도 기존 값 유지
+	waitIdle(t, c)
+	mustGet(t, c, "v3")
+}
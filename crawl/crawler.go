@@ -1,13 +1,17 @@
 package crawl
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"lolcheBot"
-	"net/http"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
@@ -15,17 +19,54 @@ import (
 )
 
 type Crawler struct {
-	mainUrl     string
-	pbeUrl      string
-	cssPath     string
-	deckCache   map[lolcheBot.Mode][]DeckMeta
-	refreshTime map[lolcheBot.Mode]time.Time
+	urls      map[lolcheBot.Mode]string // 모드별 meta 페이지
+	cssPath   string
+	cache     *metaCache
+	fetcher   Fetcher
+	snapshots *snapshotStore
+
+	mu    sync.Mutex
+	stale map[lolcheBot.Mode]time.Time // snapshot으로 대체 중인 모드와 snapshot 수집 시각
+	patch map[lolcheBot.Mode]string
+}
+
+type CrawlerConfig struct {
+	ttl          time.Duration
+	refreshAhead time.Duration
+	snapshotDir  string
+	urls         map[lolcheBot.Mode]string
+}
+
+// NewCrawlerConfig
+//   - ttl, refreshAhead : 크롤링 결과 캐시 설정. ttl이 지나면 만료되고, 만료 refreshAhead 전부터는 조회 시 백그라운드에서 갱신한다.
+//   - snapshotDir : 마지막 크롤링 결과를 저장할 디렉토리. 빈 값이면 메모리에만 보관하여 재시작 시 사라진다.
+//   - urls : 모드별 meta 페이지. main, pbe는 기본 url이 있으며 지정하면 덮어쓴다.
+func NewCrawlerConfig(ttl time.Duration, refreshAhead time.Duration, snapshotDir string, urls map[lolcheBot.Mode]string) *CrawlerConfig {
+	return &CrawlerConfig{
+		ttl:          ttl,
+		refreshAhead: refreshAhead,
+		snapshotDir:  snapshotDir,
+		urls:         urls,
+	}
 }
 
 func New() *Crawler {
+	return NewCrawler(NewCrawlerConfig(5*time.Minute, time.Minute, "", nil), defaultFetcher)
+}
+
+func NewCrawler(conf *CrawlerConfig, fetcher Fetcher) *Crawler {
+	urls := map[lolcheBot.Mode]string{
+		lolcheBot.MainMode: "https://lolchess.gg/meta",
+		lolcheBot.PbeMode:  "https://lolchess.gg/meta?pbe=true",
+	}
+	maps.Copy(urls, conf.urls)
+
 	crawler := &Crawler{
-		mainUrl: "https://lolchess.gg/meta",
-		pbeUrl:  "https://lolchess.gg/meta?pbe=true",
+		urls:      urls,
+		fetcher:   fetcher,
+		snapshots: newSnapshotStore(conf.snapshotDir),
+		stale:     make(map[lolcheBot.Mode]time.Time),
+		patch:     make(map[lolcheBot.Mode]string),
 	}
 
 	// err := crawler.UpdateCssPath("")
@@ -33,98 +74,314 @@ func New() *Crawler {
 	// 	crawler.cssPath = "#content-container > section > div.css-s9pipd.e2kj5ne0 > div > div > div > div.css-5x9ld.emls75t2 > div.css-1fu47ws.emls75t4 > div"
 	// }
 
-	crawler.deckCache = make(map[lolcheBot.Mode][]DeckMeta)
-	crawler.refreshTime = make(map[lolcheBot.Mode]time.Time)
-
-	go func() {
-		crawler.cleanCache()
-	}()
+	crawler.cache = newMetaCache(conf.ttl, conf.refreshAhead, crawler.crawlMeta)
 	return crawler
 }
 
-func (c *Crawler) cleanCache() {
-	for {
-		for key := range c.deckCache {
-			if len(c.deckCache[key]) != 0 && c.refreshTime[key].Before(time.Now().Add(time.Minute*-5)) {
-				c.deckCache[key] = nil
-				fmt.Println("CLEAR")
-			}
+// Modes는 meta url이 등록된 모드. main, pbe 다음 나머지는 이름순
+func (c *Crawler) Modes() []lolcheBot.Mode {
+	modes := []lolcheBot.Mode{lolcheBot.MainMode, lolcheBot.PbeMode}
+	others := slices.Sorted(maps.Keys(c.urls))
+	for _, mode := range others {
+		if !slices.Contains(modes, mode) {
+			modes = append(modes, mode)
 		}
-		time.Sleep(10 * time.Minute)
 	}
+	return modes
 }
 
-func (c *Crawler) Meta(mode lolcheBot.Mode) ([]string, error) {
-	var deckMeta []DeckMeta
-	var err error
-	if mode == lolcheBot.MainMode {
-		deckMeta, err = GetDeckMeta(c.mainUrl)
-	} else {
-		deckMeta, err = GetDeckMeta(c.pbeUrl)
-	}
+func (c *Crawler) Meta(mode lolcheBot.Mode) ([]lolcheBot.Deck, error) {
+	deckMeta, err := c.cache.Get(mode)
 	if err != nil {
-		return nil, fmt.Errorf("크롤링 실패. %w", err)
-	}
-	if len(deckMeta) == 0 {
-		return nil, fmt.Errorf("크롤링 조회 결과 없음")
+		return nil, err
 	}
-	dec := make([]string, len(deckMeta))
+	dec := make([]lolcheBot.Deck, len(deckMeta))
 	for i, dm := range deckMeta {
-		dec[i] = dm.Name
+		dec[i] = dm.Deck()
 	}
-	c.deckCache[mode] = deckMeta
-	c.refreshTime[mode] = time.Now()
 	return dec, nil
 }
 
-func (c *Crawler) DeckBuilderUrl(mode lolcheBot.Mode, id int) (string, error) {
-	var deckMeta []DeckMeta
-	var err error
-	if len(c.deckCache[mode]) == 0 {
-		if mode == lolcheBot.MainMode {
-			deckMeta, err = GetDeckMeta(c.mainUrl)
-		} else {
-			deckMeta, err = GetDeckMeta(c.pbeUrl)
+func (c *Crawler) DeckBuilderUrl(mode lolcheBot.Mode, key string) (string, error) {
+	deckMeta, err := c.findDeck(mode, key)
+	if err != nil {
+		return "", err
+	}
+
+	return deckMeta.BuilderUrl(), nil
+}
+
+func (c *Crawler) Composition(mode lolcheBot.Mode, key string) (lolcheBot.Deck, error) {
+	deckMeta, err := c.findDeck(mode, key)
+	if err != nil {
+		return lolcheBot.Deck{}, err
+	}
+
+	return deckMeta.Deck(), nil
+}
+
+// findDeck은 캐시에서 key로 덱을 찾는다. 캐시에 없으면 meta가 바뀌었을 수 있으므로 한 번 새로 크롤링해서 찾는다.
+func (c *Crawler) findDeck(mode lolcheBot.Mode, key string) (DeckMeta, error) {
+	deckMeta, err := c.cache.Get(mode)
+	if err != nil {
+		return DeckMeta{}, err
+	}
+	if deck, ok := findByKey(deckMeta, key); ok {
+		return deck, nil
+	}
+
+	deckMeta, err = c.cache.Refresh(mode)
+	if err != nil {
+		return DeckMeta{}, err
+	}
+	if deck, ok := findByKey(deckMeta, key); ok {
+		return deck, nil
+	}
+	return DeckMeta{}, fmt.Errorf("%w. key: %s", lolcheBot.ErrDeckNotInMeta, key)
+}
+
+func findByKey(deckMeta []DeckMeta, key string) (DeckMeta, bool) {
+	for _, dm := range deckMeta {
+		if dm.TeamBuilderKey == key {
+			return dm, true
 		}
-		if err != nil {
-			return "", fmt.Errorf("크롤링 실패. %w", err)
+	}
+	return DeckMeta{}, false
+}
+
+// Stale은 mode의 meta를 사이트 접속 실패로 저장된 snapshot에서 제공하고 있으면 snapshot 수집 시각을 반환한다.
+func (c *Crawler) Stale(mode lolcheBot.Mode) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fetchedAt, ok := c.stale[mode]
+	return fetchedAt, ok
+}
+
+// Patch는 mode의 최근 크롤링 결과의 패치 버전. 알 수 없으면 빈 문자열
+func (c *Crawler) Patch(mode lolcheBot.Mode) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.patch[mode]
+}
+
+// crawlMeta는 meta 페이지를 크롤링한다. 캐시를 거치지 않으므로 metaCache를 통해서만 호출할 것.
+// 크롤링에 실패하면 같은 url의 snapshot이 있는 경우 snapshot 결과로 대체한다.
+func (c *Crawler) crawlMeta(mode lolcheBot.Mode) ([]DeckMeta, error) {
+	url, ok := c.urls[mode]
+	if !ok {
+		return nil, fmt.Errorf("meta url이 등록되지 않은 모드. %s", mode)
+	}
+
+	prev, hasPrev := c.snapshot(mode, url)
+	snap, err := c.fetchMeta(mode, url, prev, hasPrev)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		if !hasPrev {
+			return nil, fmt.Errorf("크롤링 실패. %w", err)
 		}
-		if len(deckMeta) == 0 {
-			return "", fmt.Errorf("크롤링 조회 결과 없음")
+		log.Printf("크롤링 실패로 %s 저장된 snapshot 사용. %s", prev.FetchedAt.Format(time.DateTime), err.Error())
+		c.stale[mode] = prev.FetchedAt
+		c.patch[mode] = prev.Patch
+		return prev.Decks, nil
+	}
+	delete(c.stale, mode)
+	c.patch[mode] = snap.Patch
+	return snap.Decks, nil
+}
+
+// snapshot은 url에 대해 저장된 snapshot을 반환한다. 읽기 실패는 snapshot이 없는 것으로 본다.
+func (c *Crawler) snapshot(mode lolcheBot.Mode, url string) (snapshot, bool) {
+	snap, ok, err := c.snapshots.load(mode)
+	if err != nil {
+		log.Print(err.Error())
+		return snapshot{}, false
+	}
+	if !ok || snap.Url != url || len(snap.Decks) == 0 {
+		return snapshot{}, false
+	}
+	return snap, true
+}
+
+// fetchMeta는 fetcher가 조건부 요청을 지원하면 snapshot의 ETag, Last-Modified를 보내고, 바뀌지 않았으면 snapshot 결과를 사용한다.
+// 새로 저장한 snapshot을 반환한다.
+func (c *Crawler) fetchMeta(mode lolcheBot.Mode, url string, snap snapshot, hasSnap bool) (snapshot, error) {
+	var page Page
+	var err error
+	if cf, ok := c.fetcher.(ConditionalFetcher); ok {
+		var etag, lastModified string
+		if hasSnap {
+			etag, lastModified = snap.ETag, snap.LastModified
 		}
+		page, err = cf.FetchIfModified(url, etag, lastModified)
 	} else {
-		deckMeta = c.deckCache[mode]
+		page.Body, err = c.fetcher.Fetch(url)
+	}
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	if page.NotModified && hasSnap {
+		snap.FetchedAt = time.Now()
+		c.saveSnapshot(mode, snap)
+		return snap, nil
+	}
+
+	meta, err := extractMetaFromJSON(bytes.NewReader(page.Body))
+	if err != nil {
+		return snapshot{}, err
+	}
+	if len(meta.Decks) == 0 {
+		return snapshot{}, fmt.Errorf("크롤링 조회 결과 없음")
 	}
 
-	builderKey := deckMeta[id].TeamBuilderKey
+	snap = snapshot{
+		Url:          url,
+		ETag:         page.ETag,
+		LastModified: page.LastModified,
+		FetchedAt:    time.Now(),
+		Patch:        meta.Patch,
+		Decks:        meta.Decks,
+	}
+	c.saveSnapshot(mode, snap)
+	return snap, nil
+}
 
-	return "https://lolchess.gg/builder/guide/" + builderKey, nil
+// saveSnapshot 실패는 크롤링 결과에 영향을 주지 않으므로 기록만 한다.
+func (c *Crawler) saveSnapshot(mode lolcheBot.Mode, snap snapshot) {
+	if err := c.snapshots.save(mode, snap); err != nil {
+		log.Print(err.Error())
+	}
 }
 
-// DeckMeta represents a deck with its key and name
+// DeckMeta represents a guide deck of the meta page
 type DeckMeta struct {
-	TeamBuilderKey string `json:"teamBuilderKey"`
-	Name           string `json:"name"`
+	TeamBuilderKey   string      `json:"teamBuilderKey"`
+	Name             string      `json:"name"`
+	Tier             string      `json:"tier"`
+	Rank             int         `json:"rank"`
+	Stats            StatsMeta   `json:"stats"`
+	Units            []UnitMeta  `json:"units"`
+	Traits           []TraitMeta `json:"traits"`
+	Augments         []ItemMeta  `json:"augments"`
+	CarouselPriority []ItemMeta  `json:"carouselPriority"`
 }
 
-// GetDeckMeta fetches deck metadata (teamBuilderKey and name) from the lolchess.gg meta page
-func GetDeckMeta(url string) ([]DeckMeta, error) {
-	res, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
+// StatsMeta is the recent game statistics of a guide deck. rates are between 0 and 1
+type StatsMeta struct {
+	AvgPlacement float64 `json:"avgPlacement"`
+	Top4Rate     float64 `json:"top4Rate"`
+	WinRate      float64 `json:"winRate"`
+	PickRate     float64 `json:"pickRate"`
+	Plays        int     `json:"plays"`
+}
+
+// UnitMeta is a champion placed in a guide deck
+type UnitMeta struct {
+	ChampionKey string     `json:"championKey"`
+	Name        string     `json:"name"`
+	Cost        int        `json:"cost"`
+	Star        int        `json:"star"`
+	Items       []ItemMeta `json:"items"`
+}
+
+// TraitMeta is a trait activated by a guide deck
+type TraitMeta struct {
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	NumUnits int    `json:"numUnits"`
+	Style    string `json:"style"`
+}
+
+// ItemMeta is a keyed game object (item, augment) referenced by a guide deck
+type ItemMeta struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// UnmarshalJSON accepts both {"key","name"} objects and ["key","name"] pairs. carouselPriority uses pairs
+func (i *ItemMeta) UnmarshalJSON(b []byte) error {
+	if t := bytes.TrimSpace(b); len(t) == 0 || t[0] != '[' {
+		type plain ItemMeta
+		return json.Unmarshal(b, (*plain)(i))
+	}
+
+	var pair []string
+	if err := json.Unmarshal(b, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 {
+		return fmt.Errorf("item pair needs [key, name]. got %d values", len(pair))
 	}
-	defer res.Body.Close()
+	i.Key, i.Name = pair[0], pair[1]
+	return nil
+}
+
+// BuilderUrl returns the lolchess.gg deck builder page of the deck
+func (d DeckMeta) BuilderUrl() string {
+	return "https://lolchess.gg/builder/guide/" + d.TeamBuilderKey
+}
+
+// Deck converts the crawled payload into the bot's deck model
+func (d DeckMeta) Deck() lolcheBot.Deck {
+	deck := lolcheBot.Deck{
+		Key:  d.TeamBuilderKey,
+		Name: d.Name,
+		Tier: d.Tier,
+		Rank: d.Rank,
+		Stats: lolcheBot.DeckStats{
+			AvgPlacement: d.Stats.AvgPlacement,
+			Top4Rate:     d.Stats.Top4Rate,
+			WinRate:      d.Stats.WinRate,
+			PickRate:     d.Stats.PickRate,
+			Plays:        d.Stats.Plays,
+		},
+		Units:    make([]lolcheBot.Unit, len(d.Units)),
+		Traits:   make([]lolcheBot.Trait, len(d.Traits)),
+		Augments: itemNames(d.Augments),
+		Carousel: itemNames(d.CarouselPriority),
+	}
+	for i, u := range d.Units {
+		deck.Units[i] = lolcheBot.Unit{
+			Name:  u.Name,
+			Cost:  u.Cost,
+			Star:  u.Star,
+			Items: itemNames(u.Items),
+		}
+	}
+	for i, t := range d.Traits {
+		deck.Traits[i] = lolcheBot.Trait{
+			Name:  t.Name,
+			Count: t.NumUnits,
+			Style: t.Style,
+		}
+	}
+	return deck
+}
 
-	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+func itemNames(items []ItemMeta) []string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.Name
 	}
+	return names
+}
+
+// GetDeckMeta fetches guide deck metadata (key, name, tier, stats and composition) from the lolchess.gg meta page
+func GetDeckMeta(url string) ([]DeckMeta, error) {
+	return getDeckMeta(defaultFetcher, url)
+}
 
-	body, err := io.ReadAll(res.Body)
+func getDeckMeta(fetcher Fetcher, url string) ([]DeckMeta, error) {
+	body, err := fetcher.Fetch(url)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %w", err)
+		return nil, err
 	}
 
-	return extractDecksFromJSON(string(body))
+	return extractDecksFromJSON(bytes.NewReader(body))
 }
 
 // deprecated. web page rendering 방식 변화로 첫 조회 시 html 형식으로 오지 않음
@@ -133,12 +390,12 @@ func (c *Crawler) UpdateCssPath(target string) error {
 	if target == "" {
 		target = "초반 빌드업 요약"
 	}
-	path, err := cssPath(c.mainUrl, target)
+	path, err := cssPath(c.urls[lolcheBot.MainMode], target)
 	if err != nil {
 		return fmt.Errorf("css 경로 갱신 실패. %w", err)
 	}
 
-	sl, err := crawlTexts(c.mainUrl, path)
+	sl, err := crawlTexts(c.urls[lolcheBot.MainMode], path)
 	if err != nil {
 		return fmt.Errorf("css 경로로 조회 실패. path : %s, error: %w", path, err)
 	}
@@ -156,21 +413,13 @@ func (c *Crawler) UpdateCssPath(target string) error {
 func crawlTexts(url string, cssPath string) ([]string, error) {
 
 	// Send the request
-	res, err := http.Get(url)
+	body, err := defaultFetcher.Fetch(url)
 	if err != nil {
 		return nil, fmt.Errorf("error making request\n%w", err)
 	}
 
-	defer res.Body.Close()
-
-	// Check the response status
-	if res.StatusCode != 200 {
-		body, _ := io.ReadAll(res.Body)
-		return nil, fmt.Errorf("status code error: %d %s %s", res.StatusCode, res.Status, body)
-	}
-
 	// Create a goquery document from the response body
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("error creating document\n%w", err)
 	}
@@ -189,14 +438,9 @@ func crawlTexts(url string, cssPath string) ([]string, error) {
 }
 
 // deprecated. web page rendering 방식 변화로 첫 조회 시 html 형식으로 오지 않음
-func (c Crawler) DeckUrl(mode lolcheBot.Mode, id string) (string, error) {
+func (c *Crawler) DeckUrl(mode lolcheBot.Mode, id string) (string, error) {
 
-	var target string
-	if mode == lolcheBot.MainMode {
-		target = c.mainUrl
-	} else {
-		target = c.pbeUrl
-	}
+	target := c.urls[mode]
 	cssPath := fmt.Sprintf("#content-container > section > div.css-s9pipd.e2kj5ne0 > div:nth-child(%s) > div > div > div.css-1vo3wqf.emls75t3 > div.css-cchicn.emls75t7 > div.link-wrapper > a", id)
 
 	urlPath, err := crawlUrl(target, cssPath)
@@ -261,21 +505,13 @@ func crawlTextsFromChromedp(url string, cssPath string) ([]string, error) {
 }
 
 func crawlUrl(url string, cssPath string) (string, error) {
-	res, err := http.Get(url)
+	body, err := defaultFetcher.Fetch(url)
 	if err != nil {
 		return "", fmt.Errorf("error making request\n%w", err)
 	}
 
-	defer res.Body.Close()
-
-	// Check the response status
-	if res.StatusCode != 200 {
-		body, _ := io.ReadAll(res.Body)
-		return "", fmt.Errorf("status code error: %d %s %s", res.StatusCode, res.Status, body)
-	}
-
 	// Create a goquery document from the response body
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return "", fmt.Errorf("error creating document\n%w", err)
 	}
@@ -302,21 +538,13 @@ func crawlUrl(url string, cssPath string) (string, error) {
 func cssPath(url string, target string) (string, error) {
 
 	// Send the request
-	res, err := http.Get(url)
+	body, err := defaultFetcher.Fetch(url)
 	if err != nil {
 		return "", fmt.Errorf("error making request\n%w", err)
 	}
 
-	defer res.Body.Close()
-
-	// Check the response status
-	if res.StatusCode != 200 {
-		body, _ := io.ReadAll(res.Body)
-		return "", fmt.Errorf("status code error: %d %s %s", res.StatusCode, res.Status, body)
-	}
-
 	// Create a goquery document from the response body
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return "", fmt.Errorf("error creating document\n%w", err)
 	}
@@ -371,21 +599,13 @@ func constructCSSPath(selection *goquery.Selection) string {
 func printDocs(url string) error {
 
 	// Send the request
-	res, err := http.Get(url)
+	body, err := defaultFetcher.Fetch(url)
 	if err != nil {
 		return fmt.Errorf("error making request\n%w", err)
 	}
 
-	defer res.Body.Close()
-
-	// Check the response status
-	if res.StatusCode != 200 {
-		body, _ := io.ReadAll(res.Body)
-		return fmt.Errorf("status code error: %d %s %s", res.StatusCode, res.Status, body)
-	}
-
 	// Create a goquery document from the response body
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("error creating document\n%w", err)
 	}
@@ -394,38 +614,59 @@ func printDocs(url string) error {
 	return nil
 }
 
-// extractDecksFromJSON parses the HTML and extracts deck metadata from embedded JSON
-func extractDecksFromJSON(htmlContent string) ([]DeckMeta, error) {
-	// Find the JSON data embedded in a script tag
-	// Look for the pattern: <script id="__NEXT_DATA__" type="application/json">...JSON...</script>
-	// Or just find {"props":{...}} with balanced braces
-
-	// Find the start of the JSON
-	startIdx := strings.Index(htmlContent, `{"props":`)
-	if startIdx == -1 {
-		return nil, fmt.Errorf("no JSON data found in HTML")
-	}
-
-	// Find the matching closing brace by counting braces
-	braceCount := 0
-	endIdx := startIdx
-	for i := startIdx; i < len(htmlContent); i++ {
-		if htmlContent[i] == '{' {
-			braceCount++
-		} else if htmlContent[i] == '}' {
-			braceCount--
-			if braceCount == 0 {
-				endIdx = i + 1
-				break
-			}
-		}
+// ParseStage names the step of meta page parsing that failed
+type ParseStage string
+
+const (
+	StageScriptMissing     ParseStage = "script missing"
+	StageJsonInvalid       ParseStage = "json invalid"
+	StageGuideDecksMissing ParseStage = "guideDecks missing"
+)
+
+// ParseError is returned when deck metadata cannot be extracted from the meta page
+type ParseError struct {
+	Stage ParseStage
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("meta page parse failed (%s)", e.Stage)
 	}
+	return fmt.Sprintf("meta page parse failed (%s): %s", e.Stage, e.Err.Error())
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// metaPage is the data extracted from a meta page
+type metaPage struct {
+	Patch string // ex) 15.4. empty if the page has no patch query
+	Decks []DeckMeta
+}
 
-	if braceCount != 0 {
-		return nil, fmt.Errorf("unbalanced braces in JSON data")
+// extractDecksFromJSON parses the HTML and extracts deck metadata from the
+// <script id="__NEXT_DATA__" type="application/json"> element
+func extractDecksFromJSON(htmlContent io.Reader) ([]DeckMeta, error) {
+	page, err := extractMetaFromJSON(htmlContent)
+	if err != nil {
+		return nil, err
 	}
+	return page.Decks, nil
+}
 
-	jsonData := htmlContent[startIdx:endIdx]
+// extractMetaFromJSON extracts the guide decks and the patch version from the __NEXT_DATA__ element
+func extractMetaFromJSON(htmlContent io.Reader) (metaPage, error) {
+	doc, err := goquery.NewDocumentFromReader(htmlContent)
+	if err != nil {
+		return metaPage{}, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	script := doc.Find(`script#__NEXT_DATA__`).First()
+	if script.Length() == 0 {
+		return metaPage{}, &ParseError{Stage: StageScriptMissing}
+	}
 
 	// Parse the JSON
 	var data struct {
@@ -436,6 +677,7 @@ func extractDecksFromJSON(htmlContent string) ([]DeckMeta, error) {
 						State struct {
 							Data struct {
 								GuideDecks []DeckMeta `json:"guideDecks"`
+								Patch      string     `json:"patch"`
 							} `json:"data"`
 						} `json:"state"`
 					} `json:"queries"`
@@ -444,16 +686,24 @@ func extractDecksFromJSON(htmlContent string) ([]DeckMeta, error) {
 		} `json:"props"`
 	}
 
-	if err := json.Unmarshal([]byte(jsonData), &data); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	dec := json.NewDecoder(strings.NewReader(script.Text()))
+	if err := dec.Decode(&data); err != nil {
+		return metaPage{}, &ParseError{Stage: StageJsonInvalid, Err: err}
 	}
 
-	// Find the query that contains guideDecks
+	// Find the queries that contain guideDecks and the patch version
+	var page metaPage
 	for _, query := range data.Props.PageProps.DehydratedState.Queries {
-		if len(query.State.Data.GuideDecks) > 0 {
-			return query.State.Data.GuideDecks, nil
+		if page.Decks == nil && len(query.State.Data.GuideDecks) > 0 {
+			page.Decks = query.State.Data.GuideDecks
+		}
+		if page.Patch == "" {
+			page.Patch = query.State.Data.Patch
 		}
 	}
 
-	return nil, fmt.Errorf("no guideDecks found in JSON data")
+	if page.Decks == nil {
+		return metaPage{}, &ParseError{Stage: StageGuideDecksMissing}
+	}
+	return page, nil
 }
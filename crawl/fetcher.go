@@ -0,0 +1,234 @@
+package crawl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Fetcher는 crawler가 페이지를 가져오는 방법. 테스트에서는 fake로 교체할 수 있다.
+type Fetcher interface {
+	Fetch(url string) ([]byte, error)
+}
+
+// ConditionalFetcher는 ETag, Last-Modified로 조건부 요청을 지원하는 Fetcher.
+// crawler는 fetcher가 이를 구현하면 페이지가 바뀌지 않았을 때 저장된 snapshot을 사용한다.
+type ConditionalFetcher interface {
+	Fetcher
+	FetchIfModified(url string, etag string, lastModified string) (Page, error)
+}
+
+// Page는 조건부 요청 결과. NotModified면 Body는 비어있다.
+type Page struct {
+	Body         []byte
+	ETag         string
+	LastModified string
+	NotModified  bool
+}
+
+// HttpFetcher는 timeout, 재시도, body 크기 제한이 적용된 Fetcher
+type HttpFetcher struct {
+	client      *http.Client
+	headers     map[string]string
+	maxRetries  int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	maxBodySize int64
+	sleep       func(time.Duration)
+}
+
+type FetcherConfig struct {
+	timeout     time.Duration
+	maxRetries  int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	maxBodySize int64
+	headers     map[string]string
+	proxy       string
+}
+
+// NewFetcherConfig
+//   - timeout : 요청 1회의 제한 시간
+//   - maxRetries : 5xx, 429, 네트워크 오류 시 재시도 횟수. 대기 시간은 baseDelay부터 2배씩 늘어나며 maxDelay를 넘지 않는다.
+//   - maxBodySize : 응답 body 최대 크기(byte)
+//   - headers : 모든 요청에 추가할 header. User-Agent 미지정 시 브라우저 User-Agent 사용
+//   - proxy : proxy url. 빈 값이면 환경변수(HTTP_PROXY 등)를 따른다.
+func NewFetcherConfig(timeout time.Duration, maxRetries int, baseDelay time.Duration, maxDelay time.Duration, maxBodySize int64, headers map[string]string, proxy string) *FetcherConfig {
+	return &FetcherConfig{
+		timeout:     timeout,
+		maxRetries:  maxRetries,
+		baseDelay:   baseDelay,
+		maxDelay:    maxDelay,
+		maxBodySize: maxBodySize,
+		headers:     headers,
+		proxy:       proxy,
+	}
+}
+
+func DefaultFetcherConfig() *FetcherConfig {
+	return NewFetcherConfig(15*time.Second, 3, 500*time.Millisecond, 10*time.Second, 10<<20, nil, "")
+}
+
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+
+var defaultFetcher, _ = NewHttpFetcher(DefaultFetcherConfig())
+
+func NewHttpFetcher(conf *FetcherConfig) (*HttpFetcher, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if conf.proxy != "" {
+		proxyUrl, err := url.Parse(conf.proxy)
+		if err != nil {
+			return nil, fmt.Errorf("잘못된 proxy url. %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+
+	headers := map[string]string{"User-Agent": defaultUserAgent}
+	for k, v := range conf.headers {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+
+	return &HttpFetcher{
+		client: &http.Client{
+			Timeout:   conf.timeout,
+			Transport: transport,
+		},
+		headers:     headers,
+		maxRetries:  conf.maxRetries,
+		baseDelay:   conf.baseDelay,
+		maxDelay:    conf.maxDelay,
+		maxBodySize: conf.maxBodySize,
+		sleep:       time.Sleep,
+	}, nil
+}
+
+// StatusError는 200이 아닌 응답
+type StatusError struct {
+	StatusCode int
+	Status     string
+	retryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code error: %d %s", e.StatusCode, e.Status)
+}
+
+func (e *StatusError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// Fetch는 url의 body를 가져온다. 5xx, 429, 네트워크 오류는 backoff 후 재시도한다.
+func (f *HttpFetcher) Fetch(url string) ([]byte, error) {
+	page, err := f.FetchIfModified(url, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return page.Body, nil
+}
+
+// FetchIfModified는 etag, lastModified를 If-None-Match, If-Modified-Since로 보낸다. 304 응답이면 NotModified인 Page를 반환한다.
+func (f *HttpFetcher) FetchIfModified(url string, etag string, lastModified string) (Page, error) {
+	var err error
+	for attempt := 0; ; attempt++ {
+		var page Page
+		page, err = f.fetchOnce(url, etag, lastModified)
+		if err == nil {
+			return page, nil
+		}
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() || errors.Is(err, ErrBodyTooLarge) {
+			return Page{}, err
+		}
+		if attempt >= f.maxRetries {
+			break
+		}
+
+		delay := f.backoff(attempt)
+		if statusErr != nil && statusErr.retryAfter > delay {
+			delay = min(statusErr.retryAfter, f.maxDelay)
+		}
+		f.sleep(delay)
+	}
+	return Page{}, fmt.Errorf("%d회 시도 실패. %w", f.maxRetries+1, err)
+}
+
+func (f *HttpFetcher) fetchOnce(url string, etag string, lastModified string) (Page, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return Page{}, fmt.Errorf("error creating request: %w", err)
+	}
+	for k, v := range f.headers {
+		req.Header.Set(k, v)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return Page{}, fmt.Errorf("error making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified && (etag != "" || lastModified != "") {
+		return Page{
+			ETag:         etag,
+			LastModified: lastModified,
+			NotModified:  true,
+		}, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(res.Body, 4<<10)) // keep-alive 재사용
+		return Page{}, &StatusError{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			retryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, f.maxBodySize+1))
+	if err != nil {
+		return Page{}, fmt.Errorf("error reading response body: %w", err)
+	}
+	if int64(len(body)) > f.maxBodySize {
+		return Page{}, fmt.Errorf("%w. limit %d bytes", ErrBodyTooLarge, f.maxBodySize)
+	}
+	return Page{
+		Body:         body,
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// backoff는 attempt번째 재시도 전 대기 시간. baseDelay * 2^attempt (최대 maxDelay)의 절반 + 그 절반 내 무작위 값
+func (f *HttpFetcher) backoff(attempt int) time.Duration {
+	d := f.baseDelay << attempt
+	if d > f.maxDelay || d <= 0 {
+		d = f.maxDelay
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(half+1)
+}
+
+// parseRetryAfter는 초 단위 Retry-After만 지원한다.
+func parseRetryAfter(v string) time.Duration {
+	sec, err := strconv.Atoi(v)
+	if err != nil || sec < 0 {
+		return 0
+	}
+	return time.Duration(sec) * time.Second
+}
@@ -0,0 +1,236 @@
+package crawl
+
+import (
+	"errors"
+	"lolcheBot"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestFetcher는 실제로 대기하지 않고 대기 시간만 기록하는 fetcher
+func newTestFetcher(t *testing.T, conf *FetcherConfig) (*HttpFetcher, *[]time.Duration) {
+	t.Helper()
+	f, err := NewHttpFetcher(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delays := &[]time.Duration{}
+	f.sleep = func(d time.Duration) {
+		*delays = append(*delays, d)
+	}
+	return f, delays
+}
+
+// flakyServer는 처음 fails번은 status로 응답하고 이후 body로 응답한다.
+func flakyServer(t *testing.T, fails int32, status int, header map[string]string, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= fails {
+			for k, v := range header {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls
+}
+
+func TestFetcherRetry(t *testing.T) {
+
+	t.Run("5xx then success", func(t *testing.T) {
+		srv, calls := flakyServer(t, 2, http.StatusServiceUnavailable, nil, "ok")
+		f, delays := newTestFetcher(t, NewFetcherConfig(time.Second, 3, 100*time.Millisecond, time.Second, 1<<10, nil, ""))
+
+		body, err := f.Fetch(srv.URL)
+		if err != nil || string(body) != "ok" {
+			t.Fatalf("expected ok. got %q %v", body, err)
+		}
+		if calls.Load() != 3 || len(*delays) != 2 {
+			t.Errorf("expected 3 calls, 2 waits. got %d %v", calls.Load(), *delays)
+		}
+		// 2배씩 증가하는 backoff의 [절반, 전체] 구간
+		for i, d := range *delays {
+			max := 100 * time.Millisecond << i
+			if d < max/2 || d > max {
+				t.Errorf("delay %d out of range. %s", i, d)
+			}
+		}
+	})
+
+	t.Run("give up after max retries", func(t *testing.T) {
+		srv, calls := flakyServer(t, 100, http.StatusBadGateway, nil, "")
+		f, delays := newTestFetcher(t, NewFetcherConfig(time.Second, 2, 100*time.Millisecond, 150*time.Millisecond, 1<<10, nil, ""))
+
+		_, err := f.Fetch(srv.URL)
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+			t.Errorf("expected 502 status error. got %v", err)
+		}
+		if calls.Load() != 3 {
+			t.Errorf("expected 3 calls. got %d", calls.Load())
+		}
+		for _, d := range *delays {
+			if d > 150*time.Millisecond {
+				t.Errorf("delay should be capped by maxDelay. got %s", d)
+			}
+		}
+	})
+
+	t.Run("429 honours retry-after", func(t *testing.T) {
+		srv, calls := flakyServer(t, 1, http.StatusTooManyRequests, map[string]string{"Retry-After": "2"}, "ok")
+		f, delays := newTestFetcher(t, NewFetcherConfig(time.Second, 3, 10*time.Millisecond, 5*time.Second, 1<<10, nil, ""))
+
+		if _, err := f.Fetch(srv.URL); err != nil {
+			t.Fatal(err)
+		}
+		if calls.Load() != 2 || len(*delays) != 1 || (*delays)[0] != 2*time.Second {
+			t.Errorf("expected one 2s wait. got %d calls %v", calls.Load(), *delays)
+		}
+	})
+
+	t.Run("4xx is not retried", func(t *testing.T) {
+		srv, calls := flakyServer(t, 100, http.StatusNotFound, nil, "")
+		f, _ := newTestFetcher(t, NewFetcherConfig(time.Second, 3, time.Millisecond, time.Millisecond, 1<<10, nil, ""))
+
+		if _, err := f.Fetch(srv.URL); err == nil || calls.Load() != 1 {
+			t.Errorf("404 should fail without retry. calls %d err %v", calls.Load(), err)
+		}
+	})
+}
+
+func TestFetcherTimeout(t *testing.T) {
+	calls := &atomic.Int32{}
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	f, delays := newTestFetcher(t, NewFetcherConfig(50*time.Millisecond, 1, time.Millisecond, time.Millisecond, 1<<10, nil, ""))
+
+	start := time.Now()
+	body, err := f.Fetch(srv.URL)
+	if err != nil || string(body) != "ok" {
+		t.Fatalf("slow response should time out and be retried. got %q %v", body, err)
+	}
+	if time.Since(start) > 2*time.Second || len(*delays) != 1 {
+		t.Errorf("unexpected timing %s %v", time.Since(start), *delays)
+	}
+}
+
+func TestFetcherBodyLimit(t *testing.T) {
+	srv, calls := flakyServer(t, 0, 0, nil, strings.Repeat("a", 2048))
+	f, _ := newTestFetcher(t, NewFetcherConfig(time.Second, 3, time.Millisecond, time.Millisecond, 1024, nil, ""))
+
+	if _, err := f.Fetch(srv.URL); !errors.Is(err, ErrBodyTooLarge) || calls.Load() != 1 {
+		t.Errorf("expected ErrBodyTooLarge without retry. calls %d err %v", calls.Load(), err)
+	}
+
+	f, _ = newTestFetcher(t, NewFetcherConfig(time.Second, 3, time.Millisecond, time.Millisecond, 2048, nil, ""))
+	if body, err := f.Fetch(srv.URL); err != nil || len(body) != 2048 {
+		t.Errorf("body within limit should be read. got %d %v", len(body), err)
+	}
+}
+
+func TestFetcherHeadersAndProxy(t *testing.T) {
+	var got http.Header
+	var gotUrl string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		gotUrl = r.URL.String() // proxy 요청은 절대 경로
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	f, _ := newTestFetcher(t, NewFetcherConfig(time.Second, 0, time.Millisecond, time.Millisecond, 1<<10,
+		map[string]string{"accept-language": "ko-KR", "X-Test": "1"}, proxy.URL))
+
+	body, err := f.Fetch("http://lolchess.invalid/meta")
+	if err != nil || string(body) != "via proxy" {
+		t.Fatalf("request should go through proxy. got %q %v", body, err)
+	}
+	if gotUrl != "http://lolchess.invalid/meta" {
+		t.Errorf("unexpected proxied url %s", gotUrl)
+	}
+	if got.Get("User-Agent") != defaultUserAgent || got.Get("Accept-Language") != "ko-KR" || got.Get("X-Test") != "1" {
+		t.Errorf("configured headers not sent. %v", got)
+	}
+
+	if _, err := NewHttpFetcher(NewFetcherConfig(time.Second, 0, 0, 0, 1, nil, "://bad")); err == nil {
+		t.Error("invalid proxy url should fail")
+	}
+}
+
+// Crawler는 주입된 Fetcher로 meta 페이지를 가져온다.
+func TestCrawlerUsesFetcher(t *testing.T) {
+	calls := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		serveFixture(w, "meta_main.html")
+	}))
+	defer srv.Close()
+
+	f, delays := newTestFetcher(t, NewFetcherConfig(time.Second, 2, time.Millisecond, time.Millisecond, 1<<20, nil, ""))
+	c := NewCrawler(NewCrawlerConfig(time.Minute, 0, "", nil), f)
+	c.urls[lolcheBot.MainMode] = srv.URL
+
+	decks, err := c.Meta(lolcheBot.MainMode)
+	if err != nil || len(decks) != 6 {
+		t.Fatalf("expected 6 decks. got %d %v", len(decks), err)
+	}
+	if len(*delays) != 1 {
+		t.Errorf("expected one retry. got %v", *delays)
+	}
+}
+
+func TestFetcherConditional(t *testing.T) {
+	var gotEtag, gotSince string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEtag, gotSince = r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since")
+		if gotEtag == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 01 Jan 2025 00:00:00 GMT")
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	f, _ := newTestFetcher(t, NewFetcherConfig(time.Second, 0, time.Millisecond, time.Millisecond, 1<<10, nil, ""))
+
+	page, err := f.FetchIfModified(srv.URL, "", "")
+	if err != nil || string(page.Body) != "ok" || page.NotModified || page.ETag != `"v1"` || page.LastModified == "" {
+		t.Fatalf("unexpected page %+v %v", page, err)
+	}
+	if gotEtag != "" || gotSince != "" {
+		t.Errorf("validators should not be sent without snapshot. %q %q", gotEtag, gotSince)
+	}
+
+	page, err = f.FetchIfModified(srv.URL, page.ETag, page.LastModified)
+	if err != nil || !page.NotModified || len(page.Body) != 0 {
+		t.Fatalf("expected not modified. got %+v %v", page, err)
+	}
+	if gotSince != "Wed, 01 Jan 2025 00:00:00 GMT" {
+		t.Errorf("If-Modified-Since not sent. %q", gotSince)
+	}
+}
@@ -0,0 +1,401 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"lolcheBot"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	// go test ./crawl -run Fixture -update 로 golden 파일 갱신
+	update = flag.Bool("update", false, "update golden files in testdata")
+	// go test ./crawl -run TestCaptureMetaFixture -capture 로 lolchess.gg meta 페이지를 testdata에 저장
+	capture = flag.Bool("capture", false, "save live lolchess.gg meta pages to testdata")
+)
+
+// newFixtureServer는 testdata의 meta 페이지를 lolchess.gg와 같은 경로로 제공한다.
+// /meta → meta_main.html, /meta?pbe=true → meta_pbe.html, /fixture/{name} → testdata/{name}
+func newFixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/meta", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pbe") == "true" {
+			serveFixture(w, "meta_pbe.html")
+		} else {
+			serveFixture(w, "meta_main.html")
+		}
+	})
+	mux.HandleFunc("/fixture/", func(w http.ResponseWriter, r *http.Request) {
+		serveFixture(w, strings.TrimPrefix(r.URL.Path, "/fixture/"))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func serveFixture(w http.ResponseWriter, name string) {
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(b)
+}
+
+func assertGolden(t *testing.T, name string, got any) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		b, err := json.MarshalIndent(got, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("golden %s not found. run with -update. %v", name, err)
+	}
+	want := reflect.New(reflect.TypeOf(got))
+	if err := json.Unmarshal(b, want.Interface()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want.Elem().Interface(), got) {
+		gotJson, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("mismatch with %s\n--- got\n%s", name, gotJson)
+	}
+}
+
+func TestGetDeckMetaFixture(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	tests := []struct {
+		fixture string
+		golden  string
+		stage   ParseStage
+	}{
+		{fixture: "meta_main.html", golden: "meta_main.golden.json"},
+		{fixture: "meta_pbe.html", golden: "meta_pbe.golden.json"},
+		{fixture: "meta_brace_in_name.html", golden: "meta_brace_in_name.golden.json"},
+		{fixture: "meta_decoy_props.html", golden: "meta_main.golden.json"},
+		{fixture: "broken_no_json.html", stage: StageScriptMissing},
+		{fixture: "broken_no_next_data.html", stage: StageScriptMissing},
+		{fixture: "broken_truncated.html", stage: StageJsonInvalid},
+		{fixture: "broken_renamed_field.html", stage: StageGuideDecksMissing},
+		{fixture: "broken_empty_decks.html", stage: StageGuideDecksMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			decks, err := GetDeckMeta(srv.URL + "/fixture/" + tt.fixture)
+			if tt.stage != "" {
+				var perr *ParseError
+				if !errors.As(err, &perr) || perr.Stage != tt.stage {
+					t.Errorf("expected %q parse error. got %v (%d decks)", tt.stage, err, len(decks))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, tt.golden, decks)
+		})
+	}
+
+	t.Run("status error", func(t *testing.T) {
+		if _, err := GetDeckMeta(srv.URL + "/fixture/not_exist.html"); err == nil {
+			t.Error("404 should fail")
+		}
+	})
+}
+
+// lolchess.gg는 carouselPriority를 [key, name] 쌍으로 보낸다. fixture를 객체 형식으로 고치지 말 것
+func TestCarouselPairFixture(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	b, err := os.ReadFile(filepath.Join("testdata", "meta_main.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"carouselPriority":[["TFT_Item_InfinityEdge","무한의 대검"]`) {
+		t.Fatal("meta_main.html should keep the [key, name] carouselPriority")
+	}
+
+	decks, err := GetDeckMeta(srv.URL + "/meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ItemMeta{{Key: "TFT_Item_InfinityEdge", Name: "무한의 대검"}, {Key: "TFT_Item_MadredsBloodrazor", Name: "거인 학살자"}}
+	if !reflect.DeepEqual(decks[0].CarouselPriority, want) {
+		t.Errorf("unexpected carousel %+v", decks[0].CarouselPriority)
+	}
+}
+
+func TestItemMetaUnmarshal(t *testing.T) {
+
+	var items []ItemMeta
+	if err := json.Unmarshal([]byte(`[["a","에이"],{"key":"b","name":"비"}]`), &items); err != nil {
+		t.Fatal(err)
+	}
+	if want := []ItemMeta{{Key: "a", Name: "에이"}, {Key: "b", Name: "비"}}; !reflect.DeepEqual(items, want) {
+		t.Errorf("expect %+v. got %+v", want, items)
+	}
+
+	for _, data := range []string{`[["a"]]`, `[["a","b","c"]]`, `[[1,2]]`} {
+		if err := json.Unmarshal([]byte(data), &items); err == nil {
+			t.Errorf("%s should fail", data)
+		}
+	}
+}
+
+func TestCrawlerMetaFixture(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	c := New()
+	c.urls[lolcheBot.MainMode] = srv.URL + "/meta"
+	c.urls[lolcheBot.PbeMode] = srv.URL + "/meta?pbe=true"
+
+	main, err := c.Meta(lolcheBot.MainMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbe, err := c.Meta(lolcheBot.PbeMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(main) != 6 || main[0].Name != "빌지워터 미스 포츈" || main[0].Tier != "S" || main[0].Stats.AvgPlacement != 3.92 {
+		t.Errorf("unexpected main meta %v", main)
+	}
+	if len(pbe) != 3 || pbe[0].Name != "타곤 아펠리오스" {
+		t.Errorf("unexpected pbe meta %v", pbe)
+	}
+	if c.Patch(lolcheBot.MainMode) != "15.4" || c.Patch(lolcheBot.PbeMode) != "15.5" {
+		t.Errorf("unexpected patch %q %q", c.Patch(lolcheBot.MainMode), c.Patch(lolcheBot.PbeMode))
+	}
+
+	url, err := c.DeckBuilderUrl(lolcheBot.MainMode, main[0].Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "https://lolchess.gg/builder/guide/0932c429e79980626aeb3253f102a739ecf9fc6e" {
+		t.Errorf("unexpected url %s", url)
+	}
+
+	deck, err := c.Composition(lolcheBot.MainMode, main[0].Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deck.Units) != 6 || deck.Units[0].Name != "미스 포츈" || deck.Units[0].Star != 2 || len(deck.Units[0].Items) != 3 {
+		t.Errorf("unexpected units %+v", deck.Units)
+	}
+	if len(deck.Traits) != 3 || deck.Traits[0].Count != 5 || len(deck.Augments) != 2 || len(deck.Carousel) != 2 {
+		t.Errorf("unexpected composition %+v", deck)
+	}
+}
+
+// meta가 바뀌어 캐시에 없는 key는 다시 크롤링해서 찾고, 그래도 없으면 ErrDeckNotInMeta
+func TestCrawlerDeckByKeyFixture(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	c := New()
+	c.urls[lolcheBot.MainMode] = srv.URL + "/fixture/meta_pbe.html"
+	old, err := c.Meta(lolcheBot.MainMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.urls[lolcheBot.MainMode] = srv.URL + "/fixture/meta_main.html"
+	current, err := GetDeckMeta(c.urls[lolcheBot.MainMode])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deck, err := c.Composition(lolcheBot.MainMode, current[3].TeamBuilderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deck.Name != current[3].Name {
+		t.Errorf("expected %s. got %s", current[3].Name, deck.Name)
+	}
+
+	if _, err := c.DeckBuilderUrl(lolcheBot.MainMode, old[0].Key); !errors.Is(err, lolcheBot.ErrDeckNotInMeta) {
+		t.Errorf("deck dropped out of meta should fail with ErrDeckNotInMeta. got %v", err)
+	}
+}
+
+// 설정으로 추가한 모드도 같은 방식으로 크롤링한다.
+func TestCrawlerConfiguredModeFixture(t *testing.T) {
+	srv := newFixtureServer(t)
+
+	c := NewCrawler(NewCrawlerConfig(time.Minute, 0, "", map[lolcheBot.Mode]string{
+		"doubleup":  srv.URL + "/fixture/meta_pbe.html",
+		"hyperroll": srv.URL + "/fixture/meta_main.html",
+	}), defaultFetcher)
+
+	want := []lolcheBot.Mode{lolcheBot.MainMode, lolcheBot.PbeMode, "doubleup", "hyperroll"}
+	if modes := c.Modes(); !reflect.DeepEqual(modes, want) {
+		t.Errorf("expected %v. got %v", want, modes)
+	}
+
+	decks, err := c.Meta("doubleup")
+	if err != nil || len(decks) != 3 || c.Patch("doubleup") != "15.5" {
+		t.Errorf("unexpected doubleup meta %v %v", decks, err)
+	}
+	if _, err := c.Meta("revival"); err == nil {
+		t.Error("mode without url should fail")
+	}
+}
+
+// 사이트에서 받은 meta 페이지를 guideDecks만 줄여 meta_main.html, meta_pbe.html로 저장한다.
+// 덱의 필드는 그대로 두므로 tier, stats, units, traits, augments, carouselPriority가 실제 형식인지 함께 확인한다.
+// 저장 후 -update로 golden을 갱신하고, 덱 이름, key를 직접 확인하는 test를 새 페이지에 맞춘다.
+func TestCaptureMetaFixture(t *testing.T) {
+	if !*capture {
+		t.Skip("run with -capture to save live pages")
+	}
+
+	c := New()
+	for _, fx := range []struct {
+		mode  lolcheBot.Mode
+		name  string
+		decks int
+	}{
+		{lolcheBot.MainMode, "meta_main.html", 6},
+		{lolcheBot.PbeMode, "meta_pbe.html", 3},
+	} {
+		body, err := defaultFetcher.Fetch(c.urls[fx.mode])
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, err := trimMetaPage(body, fx.decks)
+		if err != nil {
+			t.Fatalf("%s. %v", fx.name, err)
+		}
+
+		decks, err := extractDecksFromJSON(bytes.NewReader(page))
+		if err != nil {
+			t.Fatalf("%s. %v", fx.name, err)
+		}
+		for _, deck := range decks {
+			if deck.TeamBuilderKey == "" || deck.Name == "" || deck.Tier == "" || deck.Stats.Plays == 0 ||
+				len(deck.Units) == 0 || len(deck.Traits) == 0 || len(deck.Augments) == 0 || len(deck.CarouselPriority) == 0 {
+				t.Errorf("%s. deck fields missing or renamed %+v", fx.name, deck)
+			}
+		}
+
+		if err := os.WriteFile(filepath.Join("testdata", fx.name), page, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// trimMetaPage는 __NEXT_DATA__ script만 남기고, guideDecks를 앞에서 n개로 줄인다.
+// guideDecks, patch가 없는 query는 버린다.
+func trimMetaPage(body []byte, n int) ([]byte, error) {
+	start := bytes.Index(body, []byte(`<script id="__NEXT_DATA__"`))
+	if start < 0 {
+		return nil, &ParseError{Stage: StageScriptMissing}
+	}
+	start += bytes.IndexByte(body[start:], '>') + 1
+	end := bytes.Index(body[start:], []byte("</script>"))
+	if end < 0 {
+		return nil, &ParseError{Stage: StageScriptMissing}
+	}
+
+	var data map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body[start : start+end]))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return nil, &ParseError{Stage: StageJsonInvalid, Err: err}
+	}
+
+	state, _ := dig(data, "props", "pageProps", "dehydratedState").(map[string]any)
+	queries, _ := dig(data, "props", "pageProps", "dehydratedState", "queries").([]any)
+	kept := []any{}
+	for _, q := range queries {
+		qd, _ := dig(q, "state", "data").(map[string]any)
+		if qd == nil {
+			continue
+		}
+		if decks, ok := qd["guideDecks"].([]any); ok {
+			qd["guideDecks"] = decks[:min(n, len(decks))]
+		} else if _, ok := qd["patch"]; !ok {
+			continue
+		}
+		kept = append(kept, q)
+	}
+	if state == nil || len(kept) == 0 {
+		return nil, &ParseError{Stage: StageGuideDecksMissing}
+	}
+	state["queries"] = kept
+
+	var buf bytes.Buffer
+	buf.WriteString(`<!DOCTYPE html><html lang="ko"><head><meta charSet="utf-8"/></head><body><script id="__NEXT_DATA__" type="application/json">`)
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(data); err != nil {
+		return nil, err
+	}
+	buf.Truncate(buf.Len() - 1) // Encode가 붙인 줄바꿈
+	buf.WriteString("</script></body></html>\n")
+	return buf.Bytes(), nil
+}
+
+// dig는 json object를 keys 순서로 따라간다. 없으면 nil
+func dig(v any, keys ...string) any {
+	for _, key := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func TestTrimMetaPage(t *testing.T) {
+
+	body, err := os.ReadFile(filepath.Join("testdata", "meta_main.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := trimMetaPage(body, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trimmed, err := extractMetaFromJSON(bytes.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := extractMetaFromJSON(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trimmed.Patch != full.Patch || !reflect.DeepEqual(trimmed.Decks, full.Decks[:2]) {
+		t.Errorf("trimmed page should keep the first decks and patch. got %+v", trimmed)
+	}
+	if !bytes.Contains(page, []byte(`"carouselPriority":[["TFT_Item_InfinityEdge","무한의 대검"]`)) {
+		t.Error("trimmed page should keep the payload format")
+	}
+
+	if _, err := trimMetaPage([]byte("<html></html>"), 2); err == nil {
+		t.Error("page without __NEXT_DATA__ should fail")
+	}
+}
@@ -0,0 +1,106 @@
+package crawl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"lolcheBot"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// snapshot은 모드별로 마지막으로 크롤링한 meta. 재시작 후 조건부 요청과 사이트 접속 실패 시 대체 결과로 사용한다.
+type snapshot struct {
+	Url          string     `json:"url"`
+	ETag         string     `json:"etag"`
+	LastModified string     `json:"lastModified"`
+	FetchedAt    time.Time  `json:"fetchedAt"` // 사이트에서 마지막으로 확인한 시각
+	Patch        string     `json:"patch"`
+	Decks        []DeckMeta `json:"decks"`
+}
+
+// snapshotStore는 snapshot을 dir 아래 모드별 json 파일로 저장한다. dir이 비어있으면 메모리에만 보관한다.
+type snapshotStore struct {
+	mu      sync.Mutex
+	dir     string
+	entries map[lolcheBot.Mode]snapshot
+}
+
+func newSnapshotStore(dir string) *snapshotStore {
+	return &snapshotStore{
+		dir:     dir,
+		entries: make(map[lolcheBot.Mode]snapshot),
+	}
+}
+
+func (s *snapshotStore) path(mode lolcheBot.Mode) string {
+	return filepath.Join(s.dir, "meta_"+string(mode)+".json")
+}
+
+// load는 mode의 snapshot을 반환한다. 없으면 false
+func (s *snapshotStore) load(mode lolcheBot.Mode) (snapshot, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if snap, ok := s.entries[mode]; ok {
+		return snap, true, nil
+	}
+	if s.dir == "" {
+		return snapshot{}, false, nil
+	}
+
+	b, err := os.ReadFile(s.path(mode))
+	if errors.Is(err, fs.ErrNotExist) {
+		return snapshot{}, false, nil
+	}
+	if err != nil {
+		return snapshot{}, false, fmt.Errorf("snapshot 읽기 실패. %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return snapshot{}, false, fmt.Errorf("snapshot 형식 오류. %s. %w", s.path(mode), err)
+	}
+	s.entries[mode] = snap
+	return snap, true, nil
+}
+
+// save는 임시 파일에 쓴 뒤 rename하여 쓰는 도중 종료되어도 기존 snapshot이 깨지지 않도록 한다.
+func (s *snapshotStore) save(mode lolcheBot.Mode, snap snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[mode] = snap
+	if s.dir == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("snapshot 디렉토리 생성 실패. %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "meta_*.tmp")
+	if err != nil {
+		return fmt.Errorf("snapshot 저장 실패. %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("snapshot 저장 실패. %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("snapshot 저장 실패. %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(mode)); err != nil {
+		return fmt.Errorf("snapshot 저장 실패. %w", err)
+	}
+	return nil
+}
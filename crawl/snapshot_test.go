@@ -0,0 +1,178 @@
+package crawl
+
+import (
+	"errors"
+	"lolcheBot"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// etagServer는 meta_main.html을 ETag와 함께 제공하고, If-None-Match가 맞으면 304로 응답한다. down이면 503
+type etagServer struct {
+	*httptest.Server
+	etag        atomic.Value
+	down        atomic.Bool
+	full        atomic.Int32
+	notModified atomic.Int32
+}
+
+func newEtagServer(t *testing.T) *etagServer {
+	t.Helper()
+	s := &etagServer{}
+	s.etag.Store(`"v1"`)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		etag := s.etag.Load().(string)
+		if r.Header.Get("If-None-Match") == etag {
+			s.notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.full.Add(1)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", "Wed, 01 Jan 2025 00:00:00 GMT")
+		serveFixture(w, "meta_main.html")
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newSnapshotCrawler(t *testing.T, url string, dir string) *Crawler {
+	t.Helper()
+	f, _ := newTestFetcher(t, NewFetcherConfig(time.Second, 0, time.Millisecond, time.Millisecond, 1<<20, nil, ""))
+	c := NewCrawler(NewCrawlerConfig(time.Minute, 0, dir, nil), f)
+	c.urls[lolcheBot.MainMode] = url
+	return c
+}
+
+func TestCrawlerConditionalGet(t *testing.T) {
+	srv := newEtagServer(t)
+	dir := t.TempDir()
+
+	c := newSnapshotCrawler(t, srv.URL, dir)
+	decks, err := c.Meta(lolcheBot.MainMode)
+	if err != nil || len(decks) != 6 {
+		t.Fatalf("expected 6 decks. got %d %v", len(decks), err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "meta_main.json")); err != nil {
+		t.Fatalf("snapshot should be written. %v", err)
+	}
+
+	refreshed, err := c.cache.Refresh(lolcheBot.MainMode)
+	if err != nil || len(refreshed) != 6 {
+		t.Fatalf("not modified page should reuse snapshot. got %d %v", len(refreshed), err)
+	}
+	if srv.full.Load() != 1 || srv.notModified.Load() != 1 {
+		t.Errorf("expected 1 full, 1 not modified. got %d %d", srv.full.Load(), srv.notModified.Load())
+	}
+
+	// 재시작해도 저장된 ETag로 조건부 요청
+	restarted := newSnapshotCrawler(t, srv.URL, dir)
+	decks, err = restarted.Meta(lolcheBot.MainMode)
+	if err != nil || len(decks) != 6 || decks[0].Name != "빌지워터 미스 포츈" {
+		t.Fatalf("restarted crawler should use snapshot. got %v %v", decks, err)
+	}
+	if srv.full.Load() != 1 || srv.notModified.Load() != 2 {
+		t.Errorf("expected 1 full, 2 not modified. got %d %d", srv.full.Load(), srv.notModified.Load())
+	}
+
+	// 페이지가 바뀌면 다시 받는다.
+	srv.etag.Store(`"v2"`)
+	if _, err := restarted.cache.Refresh(lolcheBot.MainMode); err != nil || srv.full.Load() != 2 {
+		t.Errorf("changed page should be fetched. full %d %v", srv.full.Load(), err)
+	}
+	if _, stale := restarted.Stale(lolcheBot.MainMode); stale {
+		t.Error("reachable site should not be stale")
+	}
+}
+
+func TestCrawlerSnapshotFallback(t *testing.T) {
+	srv := newEtagServer(t)
+	dir := t.TempDir()
+
+	c := newSnapshotCrawler(t, srv.URL, dir)
+	if _, err := c.Meta(lolcheBot.MainMode); err != nil {
+		t.Fatal(err)
+	}
+	snap, _, _ := c.snapshots.load(lolcheBot.MainMode)
+
+	srv.down.Store(true)
+	decks, err := c.cache.Refresh(lolcheBot.MainMode)
+	if err != nil || len(decks) != 6 {
+		t.Fatalf("unreachable site should fall back to snapshot. got %d %v", len(decks), err)
+	}
+	if fetchedAt, stale := c.Stale(lolcheBot.MainMode); !stale || !fetchedAt.Equal(snap.FetchedAt) {
+		t.Errorf("expected stale since %s. got %s %v", snap.FetchedAt, fetchedAt, stale)
+	}
+
+	// 재시작 후에도 디스크의 snapshot 사용
+	restarted := newSnapshotCrawler(t, srv.URL, dir)
+	if decks, err := restarted.Meta(lolcheBot.MainMode); err != nil || len(decks) != 6 {
+		t.Fatalf("restarted crawler should fall back to disk snapshot. got %d %v", len(decks), err)
+	}
+	if _, stale := restarted.Stale(lolcheBot.MainMode); !stale {
+		t.Error("restarted crawler should report stale")
+	}
+
+	srv.down.Store(false)
+	if _, err := restarted.cache.Refresh(lolcheBot.MainMode); err != nil {
+		t.Fatal(err)
+	}
+	if _, stale := restarted.Stale(lolcheBot.MainMode); stale {
+		t.Error("stale should be cleared after site recovers")
+	}
+
+	t.Run("no snapshot", func(t *testing.T) {
+		srv.down.Store(true)
+		c := newSnapshotCrawler(t, srv.URL, t.TempDir())
+		if _, err := c.Meta(lolcheBot.MainMode); err == nil {
+			t.Error("without snapshot crawl error should be returned")
+		}
+	})
+
+	t.Run("other url", func(t *testing.T) {
+		srv.down.Store(true)
+		c := newSnapshotCrawler(t, srv.URL+"/other", dir)
+		if _, err := c.Meta(lolcheBot.MainMode); err == nil {
+			t.Error("snapshot of other url should not be used")
+		}
+	})
+}
+
+// ConditionalFetcher가 아닌 Fetcher도 snapshot으로 대체된다.
+func TestCrawlerSnapshotPlainFetcher(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "meta_main.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &plainFetcher{body: body}
+	c := NewCrawler(NewCrawlerConfig(time.Minute, 0, "", nil), f)
+
+	if _, err := c.Meta(lolcheBot.MainMode); err != nil {
+		t.Fatal(err)
+	}
+	f.err = errors.New("site down")
+	if decks, err := c.cache.Refresh(lolcheBot.MainMode); err != nil || len(decks) != 6 {
+		t.Errorf("expected in memory snapshot. got %d %v", len(decks), err)
+	}
+	if _, stale := c.Stale(lolcheBot.MainMode); !stale {
+		t.Error("expected stale")
+	}
+}
+
+type plainFetcher struct {
+	body []byte
+	err  error
+}
+
+func (f *plainFetcher) Fetch(url string) ([]byte, error) {
+	return f.body, f.err
+}
@@ -4,55 +4,95 @@ import (
 	"database/sql"
 	"fmt"
 	"lolcheBot"
+	"slices"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Storage struct {
 	db *gorm.DB
 }
 
+// NewStorage는 db에 연결하고 적용되지 않은 schema migration을 모두 적용한다.
+// db schema가 지원하는 version보다 높으면 실패한다.
 func NewStorage(conf *StorageConfig) (*Storage, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", conf.user, conf.password, conf.ip, conf.port, conf.scheme)
-	sqlDB, err := sql.Open("mysql", dsn)
+	db, err := open(conf)
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := gorm.Open(mysql.New(mysql.Config{
-		Conn: sqlDB,
-	}))
+	if _, err := migrate(db, conf); err != nil {
+		return nil, fmt.Errorf("failed to migrate database. %w", err)
+	}
+
+	return &Storage{
+		db: db,
+	}, nil
+}
+
+func open(conf *StorageConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	var err error
+	switch conf.driver {
+	case DriverSqlite:
+		dialector, err = sqliteDialector(conf.path)
+	default:
+		dialector, err = mysqlDialector(conf)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if !db.Migrator().HasTable("modes") {
-		err = db.AutoMigrate(&main{}, &pbe{}, &mode{})
+	db, err := gorm.Open(dialector)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.driver == DriverSqlite {
+		// sqlite는 동시 쓰기 시 database is locked 발생하므로 커넥션 하나로 제한
+		sqlDB, err := db.DB()
 		if err != nil {
-			panic("failed to migrate database")
+			return nil, err
 		}
-		db.Model(&mode{}).Create(&mode{ // default 값은 메인모드.
-			IsMain: true,
-		})
+		sqlDB.SetMaxOpenConns(1)
+	}
+	return db, nil
+}
 
+func mysqlDialector(conf *StorageConfig) (gorm.Dialector, error) {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", conf.user, conf.password, conf.ip, conf.port, conf.scheme)
+	sqlDB, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Storage{
-		db: db,
-	}, nil
+	return mysql.New(mysql.Config{
+		Conn: sqlDB,
+	}), nil
 }
 
+const (
+	DriverMysql  = "mysql"
+	DriverSqlite = "sqlite"
+)
+
 type StorageConfig struct {
+	driver   string
 	user     string
 	password string
 	ip       string
 	port     string
 	scheme   string
+	path     string
+	chatId   int64 // chat 구분 없이 저장된 기존 기록을 옮길 chat
 }
 
 func NewStorageConfig(user string, password string, ip string, port string, scheme string) *StorageConfig {
 	return &StorageConfig{
+		driver:   DriverMysql,
 		user:     user,
 		password: password,
 		ip:       ip,
@@ -61,153 +101,503 @@ func NewStorageConfig(user string, password string, ip string, port string, sche
 	}
 }
 
-func (s Storage) Save(mode lolcheBot.Mode, name string) error {
-	if mode == lolcheBot.MainMode {
-		return s.saveMain(name)
-	} else {
-		return s.savePbe(name)
+// NewSqliteStorageConfig는 path 위치의 sqlite 파일을 사용하는 설정을 만든다. 파일이 없으면 새로 생성된다.
+func NewSqliteStorageConfig(path string) *StorageConfig {
+	return &StorageConfig{
+		driver: DriverSqlite,
+		path:   path,
 	}
 }
 
-func (s Storage) saveMain(name string) error {
+// WithChatId는 chat 구분 도입 전에 저장된 기록을 옮길 chat을 지정한다. 기존 기록이 있는 db를 migration할 때 필요하다.
+func (c *StorageConfig) WithChatId(chatId int64) *StorageConfig {
+	c.chatId = chatId
+	return c
+}
 
-	var cnt int64
-	s.db.Model(&main{}).Where("name = ?", name).Count(&cnt)
+// Save는 key가 있으면 key로 중복을 확인하고, 같은 이름의 key 없는 기록이 있으면 새로 만들지 않고 key를 부여한다.
+// key가 없으면 이름이 같은 기록이 있을 때 새로 만들지 않는다.
+// 동시에 저장해도 (chat_id, mode, season, deck) unique index로 한 건만 남고, 삭제 표시된 기록은 복구한다.
+func (s Storage) Save(chatId int64, mode lolcheBot.Mode, key string, name string) error {
+	season, err := s.Season(chatId, mode)
+	if err != nil {
+		return err
+	}
 
-	if cnt == 0 {
-		dec := main{
-			Name: name,
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var cnt int64
+		if key != "" {
+			if err := tx.Unscoped().Model(&completion{}).Where("chat_id = ? AND mode = ? AND season = ? AND deck = ?", chatId, string(mode), season, deckId(key, name)).Count(&cnt).Error; err != nil {
+				return err
+			}
+			if cnt == 0 {
+				result := tx.Model(&completion{}).Where("chat_id = ? AND mode = ? AND season = ? AND name = ? AND deck_key = ''", chatId, string(mode), season, name).
+					Updates(map[string]any{"deck_key": key, "deck": deckId(key, name)})
+				if result.Error != nil || result.RowsAffected > 0 {
+					return result.Error
+				}
+			}
+		} else {
+			if err := tx.Model(&completion{}).Where("chat_id = ? AND mode = ? AND season = ? AND name = ?", chatId, string(mode), season, name).Count(&cnt).Error; err != nil {
+				return err
+			}
+			if cnt > 0 {
+				return nil
+			}
 		}
-		result := s.db.Create(&dec)
-		if result.Error != nil {
-			return result.Error
+
+		dec := completion{
+			ChatId:  chatId,
+			Mode:    string(mode),
+			Season:  season,
+			Deck:    deckId(key, name),
+			DeckKey: key,
+			Name:    name,
 		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chat_id"}, {Name: "mode"}, {Name: "season"}, {Name: "deck"}},
+			DoUpdates: clause.Assignments(map[string]any{"deleted_at": nil}),
+		}).Create(&dec).Error
+	})
+}
+
+// Relink는 key가 부여된 같은 덱 기록이 이미 있으면 key 없는 기록을 삭제하고 key 기록을 남긴다.
+func (s Storage) Relink(chatId int64, mode lolcheBot.Mode, name string, key string, newName string) error {
+	season, err := s.Season(chatId, mode)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		nameOnly := tx.Model(&completion{}).Where("chat_id = ? AND mode = ? AND season = ? AND name = ? AND deck_key = ''", chatId, string(mode), season, name)
+
+		var cnt int64
+		if err := tx.Unscoped().Model(&completion{}).Where("chat_id = ? AND mode = ? AND season = ? AND deck = ?", chatId, string(mode), season, deckId(key, newName)).Count(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt == 0 {
+			return nameOnly.Updates(map[string]any{"deck_key": key, "deck": deckId(key, newName), "name": newName}).Error
+		}
+
+		var linked []completion
+		if err := nameOnly.Find(&linked).Error; err != nil || len(linked) == 0 {
+			return err
+		}
+		if err := tx.Unscoped().Model(&completion{}).Where("chat_id = ? AND mode = ? AND season = ? AND deck = ?", chatId, string(mode), season, deckId(key, newName)).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&linked).Error
+	})
 }
 
-func (s Storage) savePbe(name string) error {
+// DeleteAll은 현재 시즌의 기록을 backup한 뒤 삭제한다. 반환값은 backup id이며 삭제할 기록이 없으면 0
+func (s Storage) DeleteAll(chatId int64, mode lolcheBot.Mode) (int64, error) {
+	season, err := s.Season(chatId, mode)
+	if err != nil {
+		return 0, err
+	}
 
-	var cnt int64
-	s.db.Model(&pbe{}).Where("name = ?", name).Count(&cnt)
+	var id int64
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var li []completion
+		if err := tx.Where("chat_id = ? AND mode = ? AND season = ?", chatId, string(mode), season).Order("id").Find(&li).Error; err != nil {
+			return err
+		}
+		if len(li) == 0 {
+			return nil
+		}
 
-	if cnt == 0 {
-		dec := pbe{
-			Name: name,
+		backup := resetBackup{
+			ChatId:    chatId,
+			Mode:      string(mode),
+			Season:    season,
+			CreatedAt: time.Now().UTC(),
+			Decks:     make([]resetBackupDeck, len(li)),
 		}
-		result := s.db.Create(&dec)
-		if result.Error != nil {
-			return result.Error
+		for i, c := range li {
+			backup.Decks[i] = resetBackupDeck{DeckKey: c.DeckKey, Name: c.Name, CompletedAt: c.CreatedAt}
+		}
+		if err := tx.Create(&backup).Error; err != nil {
+			return err
 		}
+		id = int64(backup.ID)
+
+		return tx.Unscoped().Where("chat_id = ? AND mode = ? AND season = ?", chatId, string(mode), season).Delete(&completion{}).Error // memo. Unscopred : deleted_at으로 관리되던 삭제 여부 무시하고 수행. (delete면 싹 다 삭제. select면 deleted_at 되어있어도 조회)
+	})
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	return id, nil
 }
 
-func (s Storage) DeleteAll(mode lolcheBot.Mode) error {
+// RestoreBackup은 DeleteAll로 삭제한 기록을 삭제 당시 시즌에 다시 저장하고 복구한 기록 수를 반환한다.
+// 이미 있는 기록은 중복 저장하지 않는다.
+func (s Storage) RestoreBackup(chatId int64, mode lolcheBot.Mode, id int64) (int, error) {
+	var backup resetBackup
+	result := s.db.Where("id = ? AND chat_id = ? AND mode = ?", id, chatId, string(mode)).Preload("Decks").Limit(1).Find(&backup)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if backup.ID == 0 {
+		return 0, fmt.Errorf("없는 backup. %d", id)
+	}
 
-	if mode == lolcheBot.MainMode {
-		return s.deleteAllMain()
-	} else {
-		return s.deleteAllPbe()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, deck := range backup.Decks {
+			c := completion{
+				ChatId:  backup.ChatId,
+				Mode:    backup.Mode,
+				Season:  backup.Season,
+				Deck:    deckId(deck.DeckKey, deck.Name),
+				DeckKey: deck.DeckKey,
+				Name:    deck.Name,
+				Model:   gorm.Model{CreatedAt: deck.CompletedAt},
+			}
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "chat_id"}, {Name: "mode"}, {Name: "season"}, {Name: "deck"}},
+				DoUpdates: clause.Assignments(map[string]any{"deleted_at": nil}),
+			}).Create(&c).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
+	return len(backup.Decks), nil
 }
 
-func (s Storage) deleteAllMain() error {
-	result := s.db.Unscoped().Where("1 = 1").Delete(&main{})
+func (s Storage) Delete(chatId int64, mode lolcheBot.Mode, key string) error {
+	season, err := s.Season(chatId, mode)
+	if err != nil {
+		return err
+	}
+	result := s.db.Where("chat_id = ? AND mode = ? AND season = ? AND deck_key = ?", chatId, string(mode), season, key).Delete(&completion{})
 	if result.Error != nil {
 		return result.Error
 	}
 	return nil
 }
 
-func (s Storage) deleteAllPbe() error {
-	result := s.db.Unscoped().Where("1 = 1").Delete(&pbe{}) // memo. Unscopred : deleted_at으로 관리되던 삭제 여부 무시하고 수행. (delete면 싹 다 삭제. select면 deleted_at 되어있어도 조회)
+func (s Storage) DeleteByName(chatId int64, mode lolcheBot.Mode, name string) error {
+	season, err := s.Season(chatId, mode)
+	if err != nil {
+		return err
+	}
+	result := s.db.Where("chat_id = ? AND mode = ? AND season = ? AND name = ?", chatId, string(mode), season, name).Delete(&completion{})
 	if result.Error != nil {
 		return result.Error
 	}
 	return nil
 }
 
-func (s Storage) DeleteByName(mode lolcheBot.Mode, name string) error {
-	if mode == lolcheBot.MainMode {
-		return s.deleteMainByName(name)
-	} else {
-		return s.deletePbeByName(name)
+func (s Storage) All(chatId int64, mode lolcheBot.Mode) ([]lolcheBot.DoneDeck, error) {
+	season, err := s.Season(chatId, mode)
+	if err != nil {
+		return nil, err
 	}
+	return s.AllInSeason(chatId, mode, season)
 }
 
-func (s Storage) deleteMainByName(name string) error {
-	result := s.db.Where("name = ?", name).Delete(&main{})
+func (s Storage) AllInSeason(chatId int64, mode lolcheBot.Mode, season string) ([]lolcheBot.DoneDeck, error) {
+
+	var completions []completion
+
+	result := s.db.Model(&completion{}).Where("chat_id = ? AND mode = ? AND season = ?", chatId, string(mode), season).Select("deck_key", "name").Find(&completions)
 	if result.Error != nil {
-		return result.Error
+		return nil, result.Error
 	}
-	return nil
+
+	decs := make([]lolcheBot.DoneDeck, len(completions))
+	for i := 0; i < len(completions); i++ {
+		decs[i] = lolcheBot.DoneDeck{Key: completions[i].DeckKey, Name: completions[i].Name}
+	}
+	return decs, nil
 }
 
-func (s Storage) deletePbeByName(name string) error {
-	result := s.db.Where("name = ?", name).Delete(&pbe{})
+func (s Storage) Mode(chatId int64) (lolcheBot.Mode, error) {
+	var m mode
+	result := s.db.Where("chat_id = ?", chatId).Order("id DESC").Limit(1).Find(&m)
 	if result.Error != nil {
-		return result.Error
+		return "", result.Error
 	}
-	return nil
+	if m.Name == "" {
+		return lolcheBot.MainMode, nil
+	}
+	return lolcheBot.Mode(m.Name), nil
+}
+
+func (s Storage) SaveMode(chatId int64, currentMode lolcheBot.Mode) error {
+	var m mode
+	if err := s.db.Where("chat_id = ?", chatId).Order("id DESC").Limit(1).Find(&m).Error; err != nil {
+		return err
+	}
+	m.ChatId = chatId
+	m.Name = string(currentMode)
+	if m.ID == 0 {
+		return s.db.Create(&m).Error
+	}
+	return s.db.Select("*").Updates(&m).Error
 }
 
-func (s Storage) All(mode lolcheBot.Mode) ([]string, error) {
-	if mode == lolcheBot.MainMode {
-		return s.allMain()
-	} else {
-		return s.allPbe()
+func (s Storage) Season(chatId int64, mode lolcheBot.Mode) (string, error) {
+	var ms modeSeason
+	result := s.db.Where("chat_id = ? AND mode = ?", chatId, string(mode)).Limit(1).Find(&ms)
+	if result.Error != nil {
+		return "", result.Error
 	}
+	return ms.Name, nil
 }
 
-func (s Storage) allMain() ([]string, error) {
+func (s Storage) SaveSeason(chatId int64, mode lolcheBot.Mode, name string) error {
+	result := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&modeSeason{
+		ChatId: chatId,
+		Mode:   string(mode),
+		Name:   name,
+	})
+	return result.Error
+}
 
-	var mains []main
+// Seasons는 현재 시즌과 완료 기록이 있는 시즌 목록. 이름순
+func (s Storage) Seasons(chatId int64, mode lolcheBot.Mode) ([]string, error) {
+	current, err := s.Season(chatId, mode)
+	if err != nil {
+		return nil, err
+	}
 
-	result := s.db.Model(&main{}).Select("name").Find(&mains)
+	var names []string
+	result := s.db.Model(&completion{}).Where("chat_id = ? AND mode = ?", chatId, string(mode)).Distinct("season").Pluck("season", &names)
 	if result.Error != nil {
 		return nil, result.Error
 	}
+	if !slices.Contains(names, current) {
+		names = append(names, current)
+	}
+	slices.Sort(names)
+	return names, nil
+}
 
-	decs := make([]string, len(mains))
-	for i := 0; i < len(mains); i++ {
-		decs[i] = mains[i].Name
+func (s Storage) Strategy(chatId int64) (string, error) {
+	var st strategy
+	result := s.db.Where("chat_id = ?", chatId).Limit(1).Find(&st)
+	if result.Error != nil {
+		return "", result.Error
 	}
-	return decs, nil
+	return st.Name, nil
 }
 
-func (s Storage) allPbe() ([]string, error) {
+func (s Storage) SaveStrategy(chatId int64, name string) error {
+	result := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&strategy{
+		ChatId: chatId,
+		Name:   name,
+	})
+	return result.Error
+}
+
+func (s Storage) SaveSelection(chatId int64, mode lolcheBot.Mode, key string, name string) error {
+	result := s.db.Create(&selection{
+		ChatId:     chatId,
+		Mode:       string(mode),
+		DeckKey:    key,
+		Name:       name,
+		SelectedAt: time.Now().UTC(),
+	})
+	return result.Error
+}
 
-	var pbes []pbe
+// LastAttempted는 선택 기록과 등수 기록에서 덱 key별 가장 늦은 시각을 찾는다.
+// MAX 결과는 driver에 따라 시각으로 읽을 수 없어서 원래 row와 join해 column 값을 읽는다.
+func (s Storage) LastAttempted(chatId int64, mode lolcheBot.Mode) (map[string]time.Time, error) {
+	rtn := make(map[string]time.Time)
+	for _, src := range []struct {
+		table string
+		at    string
+	}{
+		{"selections", "selected_at"},
+		{"attempts", "played_at"},
+	} {
+		latest := s.db.Table(src.table).
+			Select("deck_key, MAX("+src.at+") AS at").
+			Where("chat_id = ? AND mode = ? AND deck_key <> ''", chatId, string(mode)).
+			Group("deck_key")
+
+		var rows []struct {
+			DeckKey string
+			At      time.Time
+		}
+		result := s.db.Table("(?) AS latest", latest).
+			Select("latest.deck_key, r."+src.at+" AS at").
+			Joins("JOIN "+src.table+" r ON r.deck_key = latest.deck_key AND r."+src.at+" = latest.at AND r.chat_id = ? AND r.mode = ?", chatId, string(mode)).
+			Scan(&rows)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		for _, row := range rows {
+			if row.At.After(rtn[row.DeckKey]) {
+				rtn[row.DeckKey] = row.At
+			}
+		}
+	}
+	return rtn, nil
+}
 
-	result := s.db.Model(&pbe{}).Select("name").Find(&pbes)
+func (s Storage) SaveMetaSnapshot(mode lolcheBot.Mode, snap lolcheBot.MetaSnapshot) error {
+	decks := make([]metaSnapshotDeck, len(snap.Decks))
+	for i, deck := range snap.Decks {
+		decks[i] = metaSnapshotDeck{
+			Position: i,
+			DeckKey:  deck.Key,
+			Name:     deck.Name,
+			Tier:     deck.Tier,
+		}
+	}
+
+	result := s.db.Create(&metaSnapshot{
+		Mode:    string(mode),
+		Patch:   snap.Patch,
+		TakenAt: snap.TakenAt.UTC(), // sqlite는 시각을 문자열로 비교하므로 UTC로 통일
+		Decks:   decks,
+	})
+	return result.Error
+}
+
+func (s Storage) MetaSnapshotAt(mode lolcheBot.Mode, at time.Time) (lolcheBot.MetaSnapshot, bool, error) {
+	var snaps []metaSnapshot
+	result := s.db.Where("mode = ? AND taken_at <= ?", string(mode), at.UTC()).
+		Order("taken_at desc").
+		Limit(1).
+		Preload("Decks", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Find(&snaps)
+	if result.Error != nil {
+		return lolcheBot.MetaSnapshot{}, false, result.Error
+	}
+	if len(snaps) == 0 {
+		return lolcheBot.MetaSnapshot{}, false, nil
+	}
+
+	snap := lolcheBot.MetaSnapshot{
+		Patch:   snaps[0].Patch,
+		TakenAt: snaps[0].TakenAt.Local(),
+		Decks:   make([]lolcheBot.Deck, len(snaps[0].Decks)),
+	}
+	for i, deck := range snaps[0].Decks {
+		snap.Decks[i] = lolcheBot.Deck{Key: deck.DeckKey, Name: deck.Name, Tier: deck.Tier}
+	}
+	return snap, true, nil
+}
+
+func (s Storage) SaveAttempt(chatId int64, mode lolcheBot.Mode, a lolcheBot.Attempt) error {
+	season, err := s.Season(chatId, mode)
+	if err != nil {
+		return err
+	}
+	result := s.db.Create(&attempt{
+		ChatId:    chatId,
+		Mode:      string(mode),
+		Season:    season,
+		DeckKey:   a.Key,
+		Name:      a.Name,
+		Placement: a.Placement,
+		PlayedAt:  a.PlayedAt.UTC(),
+	})
+	return result.Error
+}
+
+func (s Storage) Attempts(chatId int64, mode lolcheBot.Mode) ([]lolcheBot.Attempt, error) {
+	season, err := s.Season(chatId, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	var li []attempt
+	result := s.db.Where("chat_id = ? AND mode = ? AND season = ?", chatId, string(mode), season).Order("played_at, id").Find(&li)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 
-	decs := make([]string, len(pbes))
-	for i := 0; i < len(pbes); i++ {
-		decs[i] = pbes[i].Name
+	attempts := make([]lolcheBot.Attempt, len(li))
+	for i, a := range li {
+		attempts[i] = lolcheBot.Attempt{Key: a.DeckKey, Name: a.Name, Placement: a.Placement, PlayedAt: a.PlayedAt.Local()}
 	}
-	return decs, nil
+	return attempts, nil
+}
+
+func (s Storage) SavePending(chatId int64, pendings []lolcheBot.Pending) error {
+	if len(pendings) == 0 {
+		return nil
+	}
+	result := s.db.Where("chat_id = ? AND expires < ?", chatId, time.Now().Add(-lolcheBot.PendingKeep).UTC()).Delete(&pending{})
+	if result.Error != nil {
+		return result.Error
+	}
+	rows := make([]pending, len(pendings))
+	for i, p := range pendings {
+		rows[i] = pending{
+			ChatId:    chatId,
+			Kind:      string(p.Kind),
+			Mode:      string(p.Mode),
+			PendingId: p.Id,
+			DeckKey:   p.Key,
+			Name:      p.Name,
+			Expires:   p.Expires.UTC(),
+		}
+	}
+	result = s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&rows)
+	return result.Error
 }
 
-func (s Storage) Mode() lolcheBot.Mode {
-	m := mode{}
-	s.db.Model(&mode{}).Last(&m)
-	return lolcheBot.Mode(m.IsMain) // default 값을 false로 하기 위해 main.go에서의 변수명과 반대로 저장
+func (s Storage) Pending(chatId int64, kind lolcheBot.PendingKind, mode lolcheBot.Mode, id string) (lolcheBot.Pending, bool, error) {
+	var li []pending
+	result := s.db.Where("chat_id = ? AND kind = ? AND mode = ? AND pending_id = ?", chatId, string(kind), string(mode), id).Limit(1).Find(&li)
+	if result.Error != nil {
+		return lolcheBot.Pending{}, false, result.Error
+	}
+	if len(li) == 0 {
+		return lolcheBot.Pending{}, false, nil
+	}
+	p := li[0]
+	return lolcheBot.Pending{
+		Kind:    lolcheBot.PendingKind(p.Kind),
+		Mode:    lolcheBot.Mode(p.Mode),
+		Id:      p.PendingId,
+		Key:     p.DeckKey,
+		Name:    p.Name,
+		Expires: p.Expires.Local(),
+	}, true, nil
 }
 
-func (s Storage) SaveMode(currentMode lolcheBot.Mode) {
+func (s Storage) Role(id int64) (lolcheBot.Role, error) {
+	var g grant
+	result := s.db.Where("subject_id = ?", id).Limit(1).Find(&g)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return lolcheBot.Role(g.Role), nil
+}
 
-	m := mode{}
-	s.db.Last(&m)
-	m.IsMain = bool(currentMode)
-	if m.ID == 0 {
-		s.db.Model(&mode{}).Create(&m)
-	} else {
-		s.db.Select("*").Updates(&m)
+func (s Storage) Roles() (map[int64]lolcheBot.Role, error) {
+	var li []grant
+	if err := s.db.Find(&li).Error; err != nil {
+		return nil, err
 	}
+	roles := make(map[int64]lolcheBot.Role, len(li))
+	for _, g := range li {
+		roles[g.SubjectId] = lolcheBot.Role(g.Role)
+	}
+	return roles, nil
+}
+
+func (s Storage) SaveRole(id int64, role lolcheBot.Role) error {
+	result := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&grant{
+		SubjectId: id,
+		Role:      string(role),
+		GrantedAt: time.Now().UTC(),
+	})
+	return result.Error
+}
 
+func (s Storage) DeleteRole(id int64) error {
+	return s.db.Where("subject_id = ?", id).Delete(&grant{}).Error
 }
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"lolcheBot"
+	"lolcheBot/db/dbtest"
 	"os"
 	"testing"
 )
@@ -22,10 +24,38 @@ func TestMigration(t *testing.T) {
 		return
 	}
 	t.Run("mode", func(t *testing.T) {
-		mode := s.Mode()
+		mode := mustMode(t, s)
 		t.Log(mode.Str())
-		s.SaveMode(!mode)
-		mode = s.Mode()
+		next := lolcheBot.MainMode
+		if mode == lolcheBot.MainMode {
+			next = lolcheBot.PbeMode
+		}
+		if err := s.SaveMode(testChat, next); err != nil {
+			t.Fatal(err)
+		}
+		mode = mustMode(t, s)
 		t.Log(mode.Str())
 	})
 }
+
+// 로컬 mysql(127.0.0.1:3306/lolche)을 대상으로 수행. 기존 기록이 삭제되므로 테스트용 scheme에서만 실행할 것.
+func TestMysqlStorageContract(t *testing.T) {
+
+	user := os.Getenv("db_user")
+	password := os.Getenv("db_password")
+	if user == "" {
+		t.Skip("db_user 환경변수 미설정")
+	}
+
+	s, err := NewStorage(NewStorageConfig(user, password, "127.0.0.1", "3306", "lolche").WithChatId(testChat))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbtest.RunStorageContract(t, func(t *testing.T) lolcheBot.Stoage {
+		for _, model := range []any{&completion{}, &modeSeason{}, &selection{}, &metaSnapshotDeck{}, &metaSnapshot{}, &attempt{}, &resetBackupDeck{}, &resetBackup{}, &pending{}, &mode{}, &grant{}} {
+			s.db.Unscoped().Where("1 = 1").Delete(model)
+		}
+		return s
+	})
+}
@@ -0,0 +1,657 @@
+// Package dbtest는 모든 lolcheBot.Stoage 구현체가 통과해야 하는 공통 contract test를 제공한다.
+package dbtest
+
+import (
+	"lolcheBot"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// chat은 contract test에서 기록하는 chat. chat 간 격리는 "chat isolation"에서 확인한다.
+const chat int64 = 1
+
+// RunStorageContract는 newStorage로 매 subtest마다 빈 저장소를 만들어 Stoage의 동작 규약을 검증한다.
+func RunStorageContract(t *testing.T, newStorage func(t *testing.T) lolcheBot.Stoage) {
+	t.Helper()
+
+	t.Run("empty", func(t *testing.T) {
+		s := newStorage(t)
+		for _, mode := range []lolcheBot.Mode{lolcheBot.MainMode, lolcheBot.PbeMode} {
+			decs, err := s.All(chat, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(decs) != 0 {
+				t.Errorf("%s: new storage should be empty. got %v", mode.Str(), decs)
+			}
+		}
+	})
+
+	t.Run("save dedup", func(t *testing.T) {
+		s := newStorage(t)
+		mustSave(t, s, lolcheBot.MainMode, "덱1")
+		mustSave(t, s, lolcheBot.MainMode, "덱1")
+		mustSave(t, s, lolcheBot.MainMode, "덱2")
+
+		assertDecks(t, s, lolcheBot.MainMode, "덱1", "덱2")
+	})
+
+	t.Run("save by key", func(t *testing.T) {
+		s := newStorage(t)
+		mustSaveKey(t, s, lolcheBot.MainMode, "k1", "덱1")
+		mustSaveKey(t, s, lolcheBot.MainMode, "k1", "덱1 이름 변경")
+		mustSaveKey(t, s, lolcheBot.MainMode, "k2", "덱1")
+
+		assertDone(t, s, lolcheBot.MainMode,
+			lolcheBot.DoneDeck{Key: "k1", Name: "덱1"},
+			lolcheBot.DoneDeck{Key: "k2", Name: "덱1"},
+		)
+	})
+
+	t.Run("save by key links name only record", func(t *testing.T) {
+		s := newStorage(t)
+		mustSave(t, s, lolcheBot.MainMode, "덱1")
+		mustSaveKey(t, s, lolcheBot.MainMode, "k1", "덱1")
+
+		assertDone(t, s, lolcheBot.MainMode, lolcheBot.DoneDeck{Key: "k1", Name: "덱1"})
+	})
+
+	t.Run("relink", func(t *testing.T) {
+		s := newStorage(t)
+		mustSave(t, s, lolcheBot.MainMode, "덱 1")
+		mustSaveKey(t, s, lolcheBot.MainMode, "k2", "덱2")
+		mustSave(t, s, lolcheBot.PbeMode, "덱 1")
+
+		if err := s.Relink(chat, lolcheBot.MainMode, "덱 1", "k1", "덱1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Relink(chat, lolcheBot.MainMode, "덱2", "k3", "덱3"); err != nil {
+			t.Fatal(err)
+		}
+
+		assertDone(t, s, lolcheBot.MainMode,
+			lolcheBot.DoneDeck{Key: "k1", Name: "덱1"},
+			lolcheBot.DoneDeck{Key: "k2", Name: "덱2"},
+		)
+		assertDone(t, s, lolcheBot.PbeMode, lolcheBot.DoneDeck{Name: "덱 1"})
+	})
+
+	t.Run("relink merges into key record", func(t *testing.T) {
+		s := newStorage(t)
+		mustSaveKey(t, s, lolcheBot.MainMode, "k1", "덱1")
+		mustSave(t, s, lolcheBot.MainMode, "덱 1")
+
+		if err := s.Relink(chat, lolcheBot.MainMode, "덱 1", "k1", "덱1"); err != nil {
+			t.Fatal(err)
+		}
+		assertDone(t, s, lolcheBot.MainMode, lolcheBot.DoneDeck{Key: "k1", Name: "덱1"})
+	})
+
+	t.Run("concurrent save", func(t *testing.T) {
+		s := newStorage(t)
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				errs <- s.Save(chat, lolcheBot.MainMode, "k1", "덱1")
+			}()
+			go func() {
+				defer wg.Done()
+				errs <- s.Save(chat, lolcheBot.MainMode, "", "덱2")
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		assertDone(t, s, lolcheBot.MainMode,
+			lolcheBot.DoneDeck{Key: "k1", Name: "덱1"},
+			lolcheBot.DoneDeck{Name: "덱2"},
+		)
+	})
+
+	t.Run("mode isolation", func(t *testing.T) {
+		s := newStorage(t)
+		mustSave(t, s, lolcheBot.MainMode, "덱1")
+		mustSave(t, s, lolcheBot.PbeMode, "덱2")
+		mustSave(t, s, lolcheBot.PbeMode, "덱1")
+
+		assertDecks(t, s, lolcheBot.MainMode, "덱1")
+		assertDecks(t, s, lolcheBot.PbeMode, "덱1", "덱2")
+	})
+
+	t.Run("chat isolation", func(t *testing.T) {
+		s := newStorage(t)
+		const other int64 = 2
+		mustSaveKey(t, s, lolcheBot.MainMode, "k1", "덱1")
+		if err := s.Save(other, lolcheBot.MainMode, "k1", "덱1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Save(other, lolcheBot.MainMode, "k2", "덱2"); err != nil {
+			t.Fatal(err)
+		}
+		assertDecks(t, s, lolcheBot.MainMode, "덱1")
+		if decs, _ := s.All(other, lolcheBot.MainMode); len(decs) != 2 {
+			t.Errorf("other chat should keep its own records. got %v", decs)
+		}
+
+		if err := s.SaveMode(other, lolcheBot.PbeMode); err != nil {
+			t.Fatal(err)
+		}
+		if m, _ := s.Mode(chat); m != lolcheBot.MainMode {
+			t.Errorf("mode should be scoped to chat. got %s", m)
+		}
+		if err := s.SaveSeason(other, lolcheBot.MainMode, "14"); err != nil {
+			t.Fatal(err)
+		}
+		if season, _ := s.Season(chat, lolcheBot.MainMode); season != "" {
+			t.Errorf("season should be scoped to chat. got %q", season)
+		}
+		if err := s.SaveAttempt(other, lolcheBot.MainMode, lolcheBot.Attempt{Key: "k2", Name: "덱2", Placement: 1, PlayedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+		if attempts, _ := s.Attempts(chat, lolcheBot.MainMode); len(attempts) != 0 {
+			t.Errorf("attempts should be scoped to chat. got %v", attempts)
+		}
+		if err := s.SaveSelection(other, lolcheBot.MainMode, "k2", "덱2"); err != nil {
+			t.Fatal(err)
+		}
+		if last, _ := s.LastAttempted(chat, lolcheBot.MainMode); len(last) != 0 {
+			t.Errorf("selections should be scoped to chat. got %v", last)
+		}
+
+		id, err := s.DeleteAll(chat, lolcheBot.MainMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decs, _ := s.AllInSeason(other, lolcheBot.MainMode, "14"); len(decs) != 0 {
+			t.Errorf("other chat season should be empty. got %v", decs)
+		}
+		if _, err := s.RestoreBackup(other, lolcheBot.MainMode, id); err == nil {
+			t.Error("backup of other chat should not be restored")
+		}
+		if decs, _ := s.All(other, lolcheBot.MainMode); len(decs) != 0 {
+			t.Errorf("other chat current season should be empty. got %v", decs)
+		}
+	})
+
+	t.Run("delete by name", func(t *testing.T) {
+		s := newStorage(t)
+		mustSave(t, s, lolcheBot.MainMode, "덱1")
+		mustSave(t, s, lolcheBot.MainMode, "덱2")
+		mustSave(t, s, lolcheBot.PbeMode, "덱1")
+
+		if err := s.DeleteByName(chat, lolcheBot.MainMode, "덱1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteByName(chat, lolcheBot.MainMode, "없는 덱"); err != nil {
+			t.Errorf("deleting unknown deck should not fail. %v", err)
+		}
+
+		assertDecks(t, s, lolcheBot.MainMode, "덱2")
+		assertDecks(t, s, lolcheBot.PbeMode, "덱1")
+
+		mustSave(t, s, lolcheBot.MainMode, "덱1")
+		assertDecks(t, s, lolcheBot.MainMode, "덱1", "덱2")
+	})
+
+	t.Run("delete by key", func(t *testing.T) {
+		s := newStorage(t)
+		mustSaveKey(t, s, lolcheBot.MainMode, "k1", "덱1")
+		mustSaveKey(t, s, lolcheBot.MainMode, "k2", "덱2")
+		mustSave(t, s, lolcheBot.MainMode, "덱3")
+		mustSaveKey(t, s, lolcheBot.PbeMode, "k1", "덱1")
+
+		if err := s.Delete(chat, lolcheBot.MainMode, "k1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete(chat, lolcheBot.MainMode, "없는 key"); err != nil {
+			t.Errorf("deleting unknown key should not fail. %v", err)
+		}
+
+		assertDone(t, s, lolcheBot.MainMode, lolcheBot.DoneDeck{Key: "k2", Name: "덱2"}, lolcheBot.DoneDeck{Name: "덱3"})
+		assertDone(t, s, lolcheBot.PbeMode, lolcheBot.DoneDeck{Key: "k1", Name: "덱1"})
+	})
+
+	t.Run("delete all", func(t *testing.T) {
+		s := newStorage(t)
+		mustSave(t, s, lolcheBot.MainMode, "덱1")
+		mustSave(t, s, lolcheBot.MainMode, "덱2")
+		mustSave(t, s, lolcheBot.PbeMode, "덱3")
+
+		if _, err := s.DeleteAll(chat, lolcheBot.MainMode); err != nil {
+			t.Fatal(err)
+		}
+
+		assertDecks(t, s, lolcheBot.MainMode)
+		assertDecks(t, s, lolcheBot.PbeMode, "덱3")
+	})
+
+	t.Run("restore backup", func(t *testing.T) {
+		s := newStorage(t)
+		mustSaveKey(t, s, lolcheBot.MainMode, "k1", "덱1")
+		mustSave(t, s, lolcheBot.MainMode, "덱2")
+
+		id, err := s.DeleteAll(chat, lolcheBot.MainMode)
+		if err != nil || id == 0 {
+			t.Fatalf("expected backup id. got %d %v", id, err)
+		}
+		if id, err := s.DeleteAll(chat, lolcheBot.MainMode); err != nil || id != 0 {
+			t.Errorf("nothing to delete should not make backup. got %d %v", id, err)
+		}
+		if _, err := s.RestoreBackup(chat, lolcheBot.PbeMode, id); err == nil {
+			t.Error("backup of other mode should not be restored")
+		}
+
+		mustSave(t, s, lolcheBot.MainMode, "덱2")
+		if err := s.SaveSeason(chat, lolcheBot.MainMode, "14"); err != nil {
+			t.Fatal(err)
+		}
+		n, err := s.RestoreBackup(chat, lolcheBot.MainMode, id)
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 restored. got %d %v", n, err)
+		}
+		assertDecks(t, s, lolcheBot.MainMode)
+		old, _ := s.AllInSeason(chat, lolcheBot.MainMode, "")
+		slices.SortFunc(old, func(a, b lolcheBot.DoneDeck) int { return strings.Compare(a.Name, b.Name) })
+		if !slices.Equal(old, []lolcheBot.DoneDeck{{Key: "k1", Name: "덱1"}, {Name: "덱2"}}) {
+			t.Errorf("backup should be restored to its season without duplicates. got %v", old)
+		}
+	})
+
+	t.Run("mode round trip", func(t *testing.T) {
+		s := newStorage(t)
+		if m, err := s.Mode(chat); err != nil || m != lolcheBot.MainMode {
+			t.Errorf("default mode should be %s. got %s %v", lolcheBot.MainMode.Str(), m.Str(), err)
+		}
+
+		for _, want := range []lolcheBot.Mode{lolcheBot.PbeMode, "doubleup", lolcheBot.MainMode} {
+			if err := s.SaveMode(chat, want); err != nil {
+				t.Fatal(err)
+			}
+			if m, err := s.Mode(chat); err != nil || m != want {
+				t.Errorf("expected %s. got %s %v", want.Str(), m.Str(), err)
+			}
+		}
+	})
+
+	t.Run("configured mode isolation", func(t *testing.T) {
+		s := newStorage(t)
+		doubleUp := lolcheBot.Mode("doubleup")
+		mustSaveKey(t, s, doubleUp, "k1", "덱1")
+		mustSaveKey(t, s, lolcheBot.MainMode, "k2", "덱2")
+		if err := s.SaveSeason(chat, doubleUp, "14"); err != nil {
+			t.Fatal(err)
+		}
+
+		assertDecks(t, s, doubleUp)
+		assertDecks(t, s, lolcheBot.MainMode, "덱2")
+		if old, _ := s.AllInSeason(chat, doubleUp, ""); len(old) != 1 || old[0].Name != "덱1" {
+			t.Errorf("unexpected doubleup records %v", old)
+		}
+		if season, _ := s.Season(chat, lolcheBot.MainMode); season != "" {
+			t.Errorf("main season should be isolated. got %q", season)
+		}
+	})
+
+	t.Run("strategy per chat", func(t *testing.T) {
+		s := newStorage(t)
+		if name, err := s.Strategy(1); err != nil || name != "" {
+			t.Errorf("unset strategy should be empty. got %q %v", name, err)
+		}
+
+		if err := s.SaveStrategy(1, "top"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveStrategy(2, "random"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveStrategy(1, "tier"); err != nil {
+			t.Fatal(err)
+		}
+
+		if name, _ := s.Strategy(1); name != "tier" {
+			t.Errorf("expected tier. got %q", name)
+		}
+		if name, _ := s.Strategy(2); name != "random" {
+			t.Errorf("expected random. got %q", name)
+		}
+	})
+
+	t.Run("last attempted", func(t *testing.T) {
+		s := newStorage(t)
+		before := time.Now().Add(-time.Second)
+
+		if err := s.SaveSelection(chat, lolcheBot.MainMode, "k1", "덱1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveSelection(chat, lolcheBot.MainMode, "k2", "덱2"); err != nil {
+			t.Fatal(err)
+		}
+		// 이름이 바뀌어도 같은 key면 같은 덱
+		if err := s.SaveSelection(chat, lolcheBot.MainMode, "k1", "덱1 (이름 변경)"); err != nil {
+			t.Fatal(err)
+		}
+		later := time.Now().Add(time.Hour).Truncate(time.Second)
+		if err := s.SaveAttempt(chat, lolcheBot.MainMode, lolcheBot.Attempt{Key: "k2", Name: "덱2", Placement: 5, PlayedAt: later}); err != nil {
+			t.Fatal(err)
+		}
+		// 이전 시즌의 등수 기록도 도전으로 본다
+		if err := s.SaveSeason(chat, lolcheBot.MainMode, "15"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveAttempt(chat, lolcheBot.MainMode, lolcheBot.Attempt{Key: "k3", Name: "덱3", Placement: 1, PlayedAt: before}); err != nil {
+			t.Fatal(err)
+		}
+
+		last, err := s.LastAttempted(chat, lolcheBot.MainMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(last) != 3 || last["k1"].Before(before) || !last["k2"].Equal(later) || last["k3"].After(last["k1"]) {
+			t.Errorf("unexpected last attempted %v", last)
+		}
+
+		if last, _ := s.LastAttempted(chat, lolcheBot.PbeMode); len(last) != 0 {
+			t.Errorf("pbe attempts should be isolated. got %v", last)
+		}
+	})
+
+	t.Run("attempts", func(t *testing.T) {
+		s := newStorage(t)
+		now := time.Now()
+		for _, a := range []lolcheBot.Attempt{
+			{Key: "k1", Name: "덱1", Placement: 3, PlayedAt: now.Add(-time.Hour)},
+			{Key: "k1", Name: "덱1", Placement: 5, PlayedAt: now.Add(-2 * time.Hour)},
+		} {
+			if err := s.SaveAttempt(chat, lolcheBot.MainMode, a); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.SaveAttempt(chat, lolcheBot.PbeMode, lolcheBot.Attempt{Key: "k2", Name: "덱2", Placement: 1, PlayedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+
+		attempts, err := s.Attempts(chat, lolcheBot.MainMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(attempts) != 2 || attempts[0].Placement != 5 || attempts[1].Placement != 3 || attempts[1].Key != "k1" || attempts[1].Name != "덱1" {
+			t.Errorf("attempts should be ordered by time. got %+v", attempts)
+		}
+		if !attempts[1].PlayedAt.Round(time.Second).Equal(now.Add(-time.Hour).Round(time.Second)) {
+			t.Errorf("played at should be kept. got %s", attempts[1].PlayedAt)
+		}
+		if attempts, _ := s.Attempts(chat, lolcheBot.PbeMode); len(attempts) != 1 {
+			t.Errorf("pbe attempts should be isolated. got %+v", attempts)
+		}
+
+		if err := s.SaveSeason(chat, lolcheBot.MainMode, "14"); err != nil {
+			t.Fatal(err)
+		}
+		if attempts, _ := s.Attempts(chat, lolcheBot.MainMode); len(attempts) != 0 {
+			t.Errorf("attempts should be scoped to season. got %+v", attempts)
+		}
+	})
+
+	t.Run("pending", func(t *testing.T) {
+		s := newStorage(t)
+		expires := time.Now().Add(time.Hour)
+		err := s.SavePending(1, []lolcheBot.Pending{
+			{Kind: lolcheBot.PendingDeck, Mode: lolcheBot.MainMode, Id: "k1", Key: "k1", Name: "덱1", Expires: expires},
+			{Kind: lolcheBot.PendingDone, Mode: lolcheBot.MainMode, Id: "0", Name: "덱2", Expires: expires},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, ok, err := s.Pending(1, lolcheBot.PendingDeck, lolcheBot.MainMode, "k1")
+		if err != nil || !ok || p.Key != "k1" || p.Name != "덱1" || p.Kind != lolcheBot.PendingDeck || p.Mode != lolcheBot.MainMode {
+			t.Errorf("pending should be found. got %+v %v %v", p, ok, err)
+		}
+		if !p.Expires.Round(time.Second).Equal(expires.Round(time.Second)) {
+			t.Errorf("expires should be kept. got %s", p.Expires)
+		}
+		for _, tt := range []struct {
+			chatId int64
+			kind   lolcheBot.PendingKind
+			mode   lolcheBot.Mode
+			id     string
+		}{
+			{2, lolcheBot.PendingDeck, lolcheBot.MainMode, "k1"},
+			{1, lolcheBot.PendingDone, lolcheBot.MainMode, "k1"},
+			{1, lolcheBot.PendingDeck, lolcheBot.PbeMode, "k1"},
+			{1, lolcheBot.PendingDeck, lolcheBot.MainMode, "k2"},
+		} {
+			if p, ok, _ := s.Pending(tt.chatId, tt.kind, tt.mode, tt.id); ok {
+				t.Errorf("pending should be scoped. %+v got %+v", tt, p)
+			}
+		}
+
+		// 같은 대상은 덮어쓴다
+		if err := s.SavePending(1, []lolcheBot.Pending{{Kind: lolcheBot.PendingDone, Mode: lolcheBot.MainMode, Id: "0", Name: "덱3", Expires: expires}}); err != nil {
+			t.Fatal(err)
+		}
+		if p, _, _ := s.Pending(1, lolcheBot.PendingDone, lolcheBot.MainMode, "0"); p.Name != "덱3" {
+			t.Errorf("pending should be overwritten. got %+v", p)
+		}
+
+		// 만료 후 하루가 지난 기록은 다음 저장 때 정리하고, 최근 만료된 기록은 만료 안내를 위해 남긴다
+		old := []lolcheBot.Pending{
+			{Kind: lolcheBot.PendingReset, Mode: lolcheBot.MainMode, Id: "old", Expires: time.Now().Add(-48 * time.Hour)},
+			{Kind: lolcheBot.PendingReset, Mode: lolcheBot.MainMode, Id: "recent", Expires: time.Now().Add(-time.Hour)},
+		}
+		if err := s.SavePending(1, old); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SavePending(2, old); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SavePending(1, []lolcheBot.Pending{{Kind: lolcheBot.PendingUndo, Mode: lolcheBot.MainMode, Id: "7", Expires: expires}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, _ := s.Pending(1, lolcheBot.PendingReset, lolcheBot.MainMode, "old"); ok {
+			t.Error("long expired pending should be pruned")
+		}
+		if _, ok, _ := s.Pending(1, lolcheBot.PendingReset, lolcheBot.MainMode, "recent"); !ok {
+			t.Error("recently expired pending should be kept")
+		}
+		if _, ok, _ := s.Pending(2, lolcheBot.PendingReset, lolcheBot.MainMode, "old"); !ok {
+			t.Error("pending of other chat should not be pruned")
+		}
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		s := newStorage(t)
+		if role, err := s.Role(7); err != nil || role != "" {
+			t.Errorf("role should be empty before grant. got %q %v", role, err)
+		}
+		if err := s.SaveRole(7, lolcheBot.RoleMember); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveRole(-100, lolcheBot.RoleMember); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveRole(7, lolcheBot.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+		if role, _ := s.Role(7); role != lolcheBot.RoleAdmin {
+			t.Errorf("role should be overwritten. got %q", role)
+		}
+		if roles, _ := s.Roles(); len(roles) != 2 || roles[-100] != lolcheBot.RoleMember {
+			t.Errorf("unexpected roles %v", roles)
+		}
+
+		if err := s.DeleteRole(7); err != nil {
+			t.Fatal(err)
+		}
+		if role, _ := s.Role(7); role != "" {
+			t.Errorf("role should be deleted. got %q", role)
+		}
+		if err := s.DeleteRole(7); err != nil {
+			t.Errorf("deleting missing role should not fail. %v", err)
+		}
+	})
+
+	t.Run("season", func(t *testing.T) {
+		s := newStorage(t)
+		mustSaveKey(t, s, lolcheBot.MainMode, "k1", "덱1")
+		mustSave(t, s, lolcheBot.MainMode, "덱2")
+
+		if season, err := s.Season(chat, lolcheBot.MainMode); err != nil || season != "" {
+			t.Fatalf("season should be empty before set. got %q %v", season, err)
+		}
+		if err := s.SaveSeason(chat, lolcheBot.MainMode, "14"); err != nil {
+			t.Fatal(err)
+		}
+		if season, _ := s.Season(chat, lolcheBot.MainMode); season != "14" {
+			t.Errorf("expected season 14. got %q", season)
+		}
+		assertDecks(t, s, lolcheBot.MainMode)
+
+		mustSaveKey(t, s, lolcheBot.MainMode, "k1", "덱1")
+		mustSave(t, s, lolcheBot.MainMode, "덱3")
+		if err := s.Relink(chat, lolcheBot.MainMode, "덱2", "k2", "덱2"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteByName(chat, lolcheBot.MainMode, "덱1"); err != nil {
+			t.Fatal(err)
+		}
+		assertDone(t, s, lolcheBot.MainMode, lolcheBot.DoneDeck{Name: "덱3"})
+
+		old, err := s.AllInSeason(chat, lolcheBot.MainMode, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.SortFunc(old, func(a, b lolcheBot.DoneDeck) int { return strings.Compare(a.Name, b.Name) })
+		if !slices.Equal(old, []lolcheBot.DoneDeck{{Key: "k1", Name: "덱1"}, {Name: "덱2"}}) {
+			t.Errorf("previous season should be kept. got %v", old)
+		}
+		if seasons, err := s.Seasons(chat, lolcheBot.MainMode); err != nil || !slices.Equal(seasons, []string{"", "14"}) {
+			t.Errorf("unexpected seasons %q %v", seasons, err)
+		}
+
+		if _, err := s.DeleteAll(chat, lolcheBot.MainMode); err != nil {
+			t.Fatal(err)
+		}
+		assertDecks(t, s, lolcheBot.MainMode)
+		if old, _ := s.AllInSeason(chat, lolcheBot.MainMode, ""); len(old) != 2 {
+			t.Errorf("delete all should only clear current season. got %v", old)
+		}
+
+		if season, _ := s.Season(chat, lolcheBot.PbeMode); season != "" {
+			t.Errorf("pbe season should be isolated. got %q", season)
+		}
+		if seasons, _ := s.Seasons(chat, lolcheBot.PbeMode); !slices.Equal(seasons, []string{""}) {
+			t.Errorf("pbe seasons should only have current season. got %q", seasons)
+		}
+
+		if err := s.SaveSeason(chat, lolcheBot.MainMode, ""); err != nil {
+			t.Fatal(err)
+		}
+		assertDecks(t, s, lolcheBot.MainMode, "덱1", "덱2")
+	})
+
+	t.Run("meta snapshot", func(t *testing.T) {
+		s := newStorage(t)
+		day1 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local)
+		day2 := day1.AddDate(0, 0, 1)
+
+		first := lolcheBot.MetaSnapshot{Patch: "15.4", TakenAt: day1, Decks: []lolcheBot.Deck{
+			{Key: "k2", Name: "덱2", Tier: "S", Rank: 1},
+			{Key: "k1", Name: "덱1", Tier: "A"},
+			{Key: "k3", Name: "덱3", Tier: "B"},
+		}}
+		second := lolcheBot.MetaSnapshot{Patch: "15.5", TakenAt: day2, Decks: []lolcheBot.Deck{
+			{Key: "k1", Name: "덱1", Tier: "S"},
+		}}
+		for _, snap := range []lolcheBot.MetaSnapshot{second, first} {
+			if err := s.SaveMetaSnapshot(lolcheBot.MainMode, snap); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, ok, err := s.MetaSnapshotAt(lolcheBot.MainMode, day1.Add(-time.Second)); err != nil || ok {
+			t.Errorf("no snapshot before first one. got %v %v", ok, err)
+		}
+
+		got, ok, err := s.MetaSnapshotAt(lolcheBot.MainMode, day1.Add(time.Hour))
+		if err != nil || !ok {
+			t.Fatalf("expected first snapshot. got %v %v", ok, err)
+		}
+		if got.Patch != "15.4" || !got.TakenAt.Equal(day1) || len(got.Decks) != 3 {
+			t.Fatalf("unexpected snapshot %+v", got)
+		}
+		for i, want := range first.Decks {
+			if got.Decks[i].Key != want.Key || got.Decks[i].Name != want.Name || got.Decks[i].Tier != want.Tier {
+				t.Errorf("deck %d. expected %+v got %+v", i, want, got.Decks[i])
+			}
+		}
+
+		if got, ok, _ := s.MetaSnapshotAt(lolcheBot.MainMode, day2); !ok || got.Patch != "15.5" {
+			t.Errorf("expected second snapshot. got %+v", got)
+		}
+		if _, ok, _ := s.MetaSnapshotAt(lolcheBot.PbeMode, day2); ok {
+			t.Error("pbe snapshots should be isolated")
+		}
+	})
+}
+
+// mustSave는 key 없이 이름으로만 저장한다.
+func mustSave(t *testing.T, s lolcheBot.Stoage, mode lolcheBot.Mode, name string) {
+	t.Helper()
+	mustSaveKey(t, s, mode, "", name)
+}
+
+func mustSaveKey(t *testing.T, s lolcheBot.Stoage, mode lolcheBot.Mode, key string, name string) {
+	t.Helper()
+	if err := s.Save(chat, mode, key, name); err != nil {
+		t.Fatalf("save %s(%s) failed. %v", name, mode.Str(), err)
+	}
+}
+
+// assertDecks는 완료 기록의 이름만 비교한다.
+func assertDecks(t *testing.T, s lolcheBot.Stoage, mode lolcheBot.Mode, want ...string) {
+	t.Helper()
+	done, err := s.All(chat, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(done))
+	for i, d := range done {
+		got[i] = d.Name
+	}
+	slices.Sort(got)
+	want = slices.Clone(want)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("%s: expected %v. got %v", mode.Str(), want, got)
+	}
+}
+
+func assertDone(t *testing.T, s lolcheBot.Stoage, mode lolcheBot.Mode, want ...lolcheBot.DoneDeck) {
+	t.Helper()
+	got, err := s.All(chat, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmp := func(a, b lolcheBot.DoneDeck) int {
+		return strings.Compare(a.Key+a.Name, b.Key+b.Name)
+	}
+	got = slices.Clone(got)
+	slices.SortFunc(got, cmp)
+	want = slices.Clone(want)
+	slices.SortFunc(want, cmp)
+	if !slices.Equal(got, want) {
+		t.Errorf("%s: expected %v. got %v", mode.Str(), want, got)
+	}
+}
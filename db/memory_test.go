@@ -0,0 +1,13 @@
+package db
+
+import (
+	"lolcheBot"
+	"lolcheBot/db/dbtest"
+	"testing"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	dbtest.RunStorageContract(t, func(t *testing.T) lolcheBot.Stoage {
+		return lolcheBot.NewMemoryStorage()
+	})
+}
@@ -0,0 +1,393 @@
+package db
+
+import (
+	"fmt"
+	"lolcheBot"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migration은 schema를 version 하나만큼 올리는 변경. up은 transaction 안에서 실행된다.
+// 단 MySQL은 DDL(table 생성, 삭제, column 변경)을 실행할 때마다 commit하므로 실패해도 DDL은 되돌려지지 않는다.
+// up은 중간에 실패한 뒤 다시 실행해도 기록을 잃지 않고 이어서 진행할 수 있게 작성한다.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB, conf *StorageConfig) error
+}
+
+// migrations는 version 순서로 적용된다. 이미 배포된 migration은 수정하지 말고 새 version을 추가한다.
+// version 1은 현재 model로 table을 만들기 때문에, 이후 version은 기존 db에 반영할 변경(column 추가, index, data 이동)만 담당한다.
+// schema_version table이 생기기 전 db는 version 0으로 보고 처음부터 적용하므로 각 migration은 이미 반영된 상태에서도 동작해야 한다.
+var migrations = []migration{
+	{1, "create tables", func(tx *gorm.DB, _ *StorageConfig) error {
+		return tx.AutoMigrate(&completion{}, &mode{}, &modeSeason{}, &strategy{}, &selection{}, &metaSnapshot{}, &metaSnapshotDeck{})
+	}},
+	{2, "move is_main records to mode names", migrateModes},
+	{3, "default mode", func(tx *gorm.DB, _ *StorageConfig) error {
+		var cnt int64
+		if err := tx.Model(&mode{}).Count(&cnt).Error; err != nil {
+			return err
+		}
+		if cnt > 0 {
+			return nil
+		}
+		return tx.Create(&mode{Name: string(lolcheBot.MainMode)}).Error // default 값은 메인모드.
+	}},
+	{4, "unique completion deck", uniqueCompletions},
+	{5, "create attempts", func(tx *gorm.DB, _ *StorageConfig) error {
+		return tx.AutoMigrate(&attempt{})
+	}},
+	{6, "create reset backups", func(tx *gorm.DB, _ *StorageConfig) error {
+		return tx.AutoMigrate(&resetBackup{}, &resetBackupDeck{})
+	}},
+	{7, "create pendings", func(tx *gorm.DB, _ *StorageConfig) error {
+		return tx.AutoMigrate(&pending{})
+	}},
+	{8, "scope records by chat", scopeByChat},
+	{9, "create grants", func(tx *gorm.DB, _ *StorageConfig) error {
+		return tx.AutoMigrate(&grant{})
+	}},
+	{10, "key selections", keySelections},
+}
+
+// Migration은 schema migration과 적용 여부
+type Migration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time // 적용 전이면 zero
+}
+
+func (m Migration) Applied() bool {
+	return !m.AppliedAt.IsZero()
+}
+
+// Migrator는 storage를 열지 않고 schema migration 상태를 조회하거나 적용한다.
+type Migrator struct {
+	db   *gorm.DB
+	conf *StorageConfig
+}
+
+func NewMigrator(conf *StorageConfig) (*Migrator, error) {
+	db, err := open(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, conf: conf}, nil
+}
+
+// Status는 db의 현재 schema version과 알려진 migration별 적용 여부를 반환한다.
+func (m *Migrator) Status() (int, []Migration, error) {
+	applied, err := appliedVersions(m.db)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	li := make([]Migration, len(migrations))
+	for i, mig := range migrations {
+		li[i] = Migration{Version: mig.version, Name: mig.name, AppliedAt: applied[mig.version].AppliedAt}
+	}
+	return currentVersion(applied), li, nil
+}
+
+// Up은 적용되지 않은 migration을 모두 적용하고 적용한 migration을 반환한다.
+func (m *Migrator) Up() ([]Migration, error) {
+	return migrate(m.db, m.conf)
+}
+
+// LatestVersion은 알려진 마지막 schema version
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate는 현재 version 이후의 migration을 하나씩 transaction으로 적용한다.
+// 실패하면 이전까지 적용한 migration만 반영된 상태로 멈춘다.
+func migrate(db *gorm.DB, conf *StorageConfig) ([]Migration, error) {
+	if err := db.AutoMigrate(&schemaVersion{}); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	current := currentVersion(applied)
+	if current > LatestVersion() {
+		return nil, fmt.Errorf("db schema version %d가 지원하는 version %d보다 높음. 최신 버전으로 실행 필요", current, LatestVersion())
+	}
+
+	var done []Migration
+	for _, mig := range migrations {
+		if mig.version <= current {
+			continue
+		}
+
+		v := schemaVersion{Version: mig.version, Name: mig.name, AppliedAt: time.Now()}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := mig.up(tx, conf); err != nil {
+				return err
+			}
+			return tx.Create(&v).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d(%s) 실패. %w", mig.version, mig.name, err)
+		}
+		done = append(done, Migration{Version: v.Version, Name: v.Name, AppliedAt: v.AppliedAt})
+	}
+	return done, nil
+}
+
+// appliedVersions는 적용된 migration 기록. schema_version table이 없으면 빈 값
+func appliedVersions(db *gorm.DB) (map[int]schemaVersion, error) {
+	applied := make(map[int]schemaVersion)
+	if !db.Migrator().HasTable(&schemaVersion{}) {
+		return applied, nil
+	}
+
+	var li []schemaVersion
+	if err := db.Find(&li).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range li {
+		applied[v.Version] = v
+	}
+	return applied, nil
+}
+
+func currentVersion(applied map[int]schemaVersion) int {
+	current := 0
+	for version := range applied {
+		current = max(current, version)
+	}
+	return current
+}
+
+// isMainCase는 is_main column을 모드 이름으로 바꾸는 sql
+const isMainCase = "CASE WHEN is_main THEN '" + string(lolcheBot.MainMode) + "' ELSE '" + string(lolcheBot.PbeMode) + "' END"
+
+// migrateModes는 bool(is_main)로 모드를 구분하던 기존 db를 모드 이름 기반 schema로 옮긴다. 이미 옮겼으면 아무것도 하지 않는다.
+//   - mains, pbes table → completions
+//   - seasons table → mode_seasons
+//   - modes, selections, meta_snapshots의 is_main column → 모드 이름 column
+func migrateModes(tx *gorm.DB, _ *StorageConfig) error {
+	if err := moveCompletions(tx, &main{}, "mains", lolcheBot.MainMode); err != nil {
+		return err
+	}
+	if err := moveCompletions(tx, &pbe{}, "pbes", lolcheBot.PbeMode); err != nil {
+		return err
+	}
+
+	if tx.Migrator().HasTable("seasons") {
+		err := tx.Exec("INSERT INTO mode_seasons (chat_id, mode, name) SELECT 0, " + isMainCase + ", name FROM seasons").Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Migrator().DropTable("seasons"); err != nil {
+			return err
+		}
+	}
+
+	if tx.Migrator().HasIndex(&metaSnapshot{}, "idx_meta_snapshot_taken") { // is_main을 포함한 index
+		if err := tx.Migrator().DropIndex(&metaSnapshot{}, "idx_meta_snapshot_taken"); err != nil {
+			return err
+		}
+	}
+	if err := replaceIsMain(tx, &mode{}, "modes", "name"); err != nil {
+		return err
+	}
+	if err := replaceIsMain(tx, &selection{}, "selections", "mode"); err != nil {
+		return err
+	}
+	return replaceIsMain(tx, &metaSnapshot{}, "meta_snapshots", "mode")
+}
+
+// moveCompletions는 모드별 table의 기록을 삭제 표시된 기록까지 completions로 옮기고 table을 삭제한다.
+func moveCompletions(tx *gorm.DB, legacy any, table string, mode lolcheBot.Mode) error {
+	if !tx.Migrator().HasTable(table) {
+		return nil
+	}
+	// key, 시즌 도입 전 table에도 column을 추가해서 한 번에 옮긴다.
+	if err := tx.Migrator().AutoMigrate(legacy); err != nil {
+		return err
+	}
+
+	err := tx.Exec("INSERT INTO completions (mode, season, deck_key, name, created_at, updated_at, deleted_at) "+
+		"SELECT ?, season, deck_key, name, created_at, updated_at, deleted_at FROM "+table, string(mode)).Error
+	if err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(table)
+}
+
+// replaceIsMain은 is_main column 값을 모드 이름으로 column에 옮기고 is_main column을 삭제한다.
+func replaceIsMain(tx *gorm.DB, model any, table string, column string) error {
+	if !tx.Migrator().HasColumn(model, "is_main") {
+		return nil
+	}
+
+	// column 삭제가 실패해 다시 호출되어도 이미 옮긴 값은 덮어쓰지 않는다.
+	if err := tx.Exec("UPDATE " + table + " SET " + column + " = " + isMainCase + " WHERE " + column + " = ''").Error; err != nil {
+		return err
+	}
+	return tx.Migrator().DropColumn(model, "is_main")
+}
+
+// uniqueCompletions는 완료 기록에 덱 식별값을 채우고, 같은 덱의 중복 기록을 하나만 남긴 뒤 unique index를 만든다.
+// 중복 기록 중 삭제 표시되지 않은 가장 오래된 기록을 남긴다.
+func uniqueCompletions(tx *gorm.DB, _ *StorageConfig) error {
+	if err := tx.AutoMigrate(&completion{}); err != nil {
+		return err
+	}
+	err := tx.Exec("UPDATE completions SET deck = CASE WHEN deck_key <> '' THEN deck_key ELSE COALESCE(name, '') END WHERE deck = ''").Error
+	if err != nil {
+		return err
+	}
+
+	var li []completion
+	if err := tx.Unscoped().Select("id", "mode", "season", "deck", "deleted_at").Order("id").Find(&li).Error; err != nil {
+		return err
+	}
+	type deckKey struct{ mode, season, deck string }
+	kept := make(map[deckKey]int)
+	var dup []uint
+	for i, c := range li {
+		k := deckKey{c.Mode, c.Season, c.Deck}
+		j, ok := kept[k]
+		if !ok {
+			kept[k] = i
+			continue
+		}
+		if li[j].DeletedAt.Valid && !c.DeletedAt.Valid {
+			kept[k] = i
+			dup = append(dup, li[j].ID)
+		} else {
+			dup = append(dup, c.ID)
+		}
+	}
+	if len(dup) > 0 {
+		if err := tx.Unscoped().Delete(&completion{}, dup).Error; err != nil {
+			return err
+		}
+	}
+
+	if tx.Migrator().HasIndex(&completion{}, "idx_completion_deck") {
+		return nil
+	}
+	return tx.Exec("CREATE UNIQUE INDEX idx_completion_deck ON completions (mode, season, deck)").Error
+}
+
+// keySelections는 선택 기록에 deck_key column을 추가하고, 이름만 있는 기록은 같은 이름의 완료, 등수, meta 기록에서 key를 찾아 채운다.
+// 찾지 못한 기록은 빈 key로 남아 마지막 도전 시각 계산에서 제외된다.
+func keySelections(tx *gorm.DB, _ *StorageConfig) error {
+	if err := tx.AutoMigrate(&selection{}); err != nil {
+		return err
+	}
+	for _, src := range []string{
+		"SELECT MAX(c.deck_key) FROM completions c WHERE c.chat_id = selections.chat_id AND c.mode = selections.mode AND c.name = selections.name AND c.deck_key <> ''",
+		"SELECT MAX(a.deck_key) FROM attempts a WHERE a.chat_id = selections.chat_id AND a.mode = selections.mode AND a.name = selections.name AND a.deck_key <> ''",
+		"SELECT MAX(d.deck_key) FROM meta_snapshot_decks d JOIN meta_snapshots m ON m.id = d.meta_snapshot_id WHERE m.mode = selections.mode AND d.name = selections.name AND d.deck_key <> ''",
+	} {
+		if err := tx.Exec("UPDATE selections SET deck_key = COALESCE((" + src + "), '') WHERE deck_key = ''").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildModeSeasons는 mode_seasons를 chat_id를 포함한 primary key로 다시 만든다.
+// MySQL은 DDL을 transaction으로 되돌릴 수 없으므로 새 table에 기록을 복사한 뒤 이름을 바꾼다.
+// 중간에 실패해도 기존 기록은 mode_seasons 또는 mode_seasons_old에 남고, 다시 실행하면 이어서 진행한다.
+func rebuildModeSeasons(tx *gorm.DB) error {
+	const building, old = "mode_seasons_new", "mode_seasons_old"
+
+	// 이름 바꾸기까지 끝났다면 mode_seasons가 새 table이므로 남은 이전 table만 지운다.
+	for _, leftover := range []string{building, old} {
+		if err := tx.Migrator().DropTable(leftover); err != nil {
+			return err
+		}
+	}
+	if err := tx.Table(building).Migrator().CreateTable(&modeSeason{}); err != nil {
+		return err
+	}
+	chatId := "0"
+	if tx.Migrator().HasColumn(&modeSeason{}, "chat_id") {
+		chatId = "COALESCE(chat_id, 0)"
+	}
+	if err := tx.Exec("INSERT INTO " + building + " (chat_id, mode, name) SELECT " + chatId + ", mode, name FROM mode_seasons").Error; err != nil {
+		return err
+	}
+
+	if tx.Dialector.Name() == DriverMysql {
+		// 한 문장으로 바꿔야 mode_seasons가 없는 순간이 생기지 않는다.
+		if err := tx.Exec("RENAME TABLE mode_seasons TO " + old + ", " + building + " TO mode_seasons").Error; err != nil {
+			return err
+		}
+	} else {
+		if err := tx.Migrator().RenameTable("mode_seasons", old); err != nil {
+			return err
+		}
+		if err := tx.Migrator().RenameTable(building, "mode_seasons"); err != nil {
+			return err
+		}
+	}
+	return tx.Migrator().DropTable(old)
+}
+
+// chatTables는 chat별로 기록하는 table
+var chatTables = []string{"completions", "modes", "mode_seasons", "selections", "attempts", "reset_backups"}
+
+// scopeByChat은 chat 구분 없이 저장된 기록을 conf의 chat 기록으로 옮긴다.
+// mode_seasons는 primary key가 바뀌므로 table을 다시 만들고, completions의 unique index에 chat_id를 추가한다.
+func scopeByChat(tx *gorm.DB, conf *StorageConfig) error {
+	if err := tx.AutoMigrate(&completion{}, &mode{}, &selection{}, &attempt{}, &resetBackup{}); err != nil {
+		return err
+	}
+	// version 1의 AutoMigrate로 chat_id column만 추가된 경우도 있으므로 primary key를 맞추기 위해 항상 다시 만든다.
+	if err := rebuildModeSeasons(tx); err != nil {
+		return err
+	}
+
+	for _, idx := range []struct {
+		model any
+		name  string
+	}{
+		{&completion{}, "idx_completion_deck"},
+		{&completion{}, "idx_completion_mode_season"},
+		{&attempt{}, "idx_attempt_mode_season"},
+		{&selection{}, "idx_selections_mode"},
+	} {
+		if tx.Migrator().HasIndex(idx.model, idx.name) {
+			if err := tx.Migrator().DropIndex(idx.model, idx.name); err != nil {
+				return err
+			}
+		}
+	}
+	if !tx.Migrator().HasIndex(&completion{}, "idx_completion_chat_deck") {
+		if err := tx.Exec("CREATE UNIQUE INDEX idx_completion_chat_deck ON completions (chat_id, mode, season, deck)").Error; err != nil {
+			return err
+		}
+	}
+
+	if conf.chatId == 0 {
+		// 옮길 chat이 없으면 기본 모드 외의 기록이 없을 때만 진행한다.
+		for _, table := range chatTables {
+			if table == "modes" {
+				continue
+			}
+			var cnt int64
+			if err := tx.Table(table).Where("chat_id = 0 OR chat_id IS NULL").Count(&cnt).Error; err != nil {
+				return err
+			}
+			if cnt > 0 {
+				return fmt.Errorf("%s의 기존 기록을 옮길 chat 없음. telegram.chatId 설정 필요", table)
+			}
+		}
+		return nil
+	}
+	for _, table := range chatTables {
+		if err := tx.Table(table).Where("chat_id = 0 OR chat_id IS NULL").Update("chat_id", conf.chatId).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
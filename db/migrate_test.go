@@ -0,0 +1,259 @@
+package db
+
+import (
+	"lolcheBot"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrationOrder(t *testing.T) {
+	for i, mig := range migrations {
+		if mig.version != i+1 {
+			t.Errorf("migration versions should be sequential from 1. got %d at %d", mig.version, i)
+		}
+	}
+}
+
+func TestMigratorStatusAndUp(t *testing.T) {
+	conf := NewSqliteStorageConfig(filepath.Join(t.TempDir(), "lolche.db"))
+	m, err := NewMigrator(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, li, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != 0 || len(li) != LatestVersion() {
+		t.Fatalf("new db should be version 0 with every migration pending. got %d %v", current, li)
+	}
+	for _, mig := range li {
+		if mig.Applied() {
+			t.Errorf("migration %d should be pending", mig.Version)
+		}
+	}
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != LatestVersion() {
+		t.Errorf("every migration should be applied. got %v", applied)
+	}
+	if applied, _ := m.Up(); len(applied) != 0 {
+		t.Errorf("nothing left to apply. got %v", applied)
+	}
+
+	current, li, _ = m.Status()
+	if current != LatestVersion() {
+		t.Errorf("expected version %d. got %d", LatestVersion(), current)
+	}
+	for _, mig := range li {
+		if !mig.Applied() {
+			t.Errorf("migration %d should be applied", mig.Version)
+		}
+	}
+
+	// migrate 후 기동해도 다시 적용하지 않는다.
+	s, err := NewStorage(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cnt int64
+	s.db.Model(&mode{}).Count(&cnt)
+	if cnt != 1 {
+		t.Errorf("default mode should be created once. got %d", cnt)
+	}
+}
+
+// 알려진 것보다 높은 schema version의 db로는 기동하지 않는다.
+func TestSqliteNewerSchema(t *testing.T) {
+	conf := NewSqliteStorageConfig(filepath.Join(t.TempDir(), "lolche.db"))
+	s, err := NewStorage(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.db.Create(&schemaVersion{Version: LatestVersion() + 1, Name: "future"})
+
+	_, err = NewStorage(conf)
+	if err == nil || !strings.Contains(err.Error(), "schema version") {
+		t.Errorf("newer schema should be refused. got %v", err)
+	}
+
+	m, _ := NewMigrator(conf)
+	if current, _, err := m.Status(); err != nil || current != LatestVersion()+1 {
+		t.Errorf("status should report newer version. got %d %v", current, err)
+	}
+	if _, err := m.Up(); err == nil {
+		t.Error("up should refuse newer schema")
+	}
+}
+
+// 실패한 migration은 기록되지 않고 반영한 변경도 되돌린다.
+func TestMigrateRollback(t *testing.T) {
+	conf := NewSqliteStorageConfig(filepath.Join(t.TempDir(), "lolche.db"))
+	s, err := NewStorage(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = append(saved[:len(saved):len(saved)], migration{LatestVersion() + 1, "broken", func(tx *gorm.DB, _ *StorageConfig) error {
+		if err := tx.Create(&strategy{ChatId: 1, Name: "top"}).Error; err != nil {
+			return err
+		}
+		return tx.Exec("SELECT * FROM missing_table").Error
+	}})
+
+	if _, err := migrate(s.db, conf); err == nil {
+		t.Fatal("broken migration should fail")
+	}
+	applied, _ := appliedVersions(s.db)
+	if currentVersion(applied) != len(saved) {
+		t.Errorf("failed migration should not be recorded. got %v", applied)
+	}
+	if name, _ := s.Strategy(1); name != "" {
+		t.Errorf("failed migration should be rolled back. got %q", name)
+	}
+}
+
+// unique index 도입 전 db의 중복 완료 기록은 하나만 남기고, 이후 중복 저장은 막는다.
+func TestSqliteUniqueCompletions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := gorm.Open(sqlite.Open(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE `completions` (`id` integer PRIMARY KEY AUTOINCREMENT,`mode` text NOT NULL DEFAULT '',`season` text NOT NULL DEFAULT '',`deck_key` text NOT NULL DEFAULT '',`name` text,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime)",
+		"CREATE TABLE `modes` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL DEFAULT '')",
+		"INSERT INTO modes (name) VALUES ('pbe')",
+		"INSERT INTO completions (mode, deck_key, name) VALUES ('main', 'k1', '덱1'), ('main', 'k1', '덱1'), ('pbe', 'k1', '덱1')",
+		"INSERT INTO completions (mode, name, deleted_at) VALUES ('main', '덱2', '2025-01-01 00:00:00+00:00')",
+		"INSERT INTO completions (mode, name) VALUES ('main', '덱2')",
+	} {
+		if err := legacy.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	sqlDB, _ := legacy.DB()
+	sqlDB.Close()
+
+	s, err := NewStorage(NewSqliteStorageConfig(path).WithChatId(testChat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mustMode(t, s) != lolcheBot.PbeMode {
+		t.Errorf("existing mode should be kept")
+	}
+
+	var li []completion
+	s.db.Unscoped().Order("id").Find(&li)
+	if len(li) != 3 || li[0].ID != 1 || li[1].ID != 3 || li[2].ID != 5 || li[2].DeletedAt.Valid {
+		t.Errorf("oldest record and live record should be kept. got %+v", li)
+	}
+	if decs, _ := s.All(testChat, lolcheBot.MainMode); len(decs) != 2 {
+		t.Errorf("unexpected main records %v", decs)
+	}
+
+	err = s.db.Create(&completion{ChatId: testChat, Mode: "main", Deck: "k1", DeckKey: "k1", Name: "덱1"}).Error
+	if err == nil {
+		t.Error("duplicate record should violate unique index")
+	}
+	if err := s.db.Create(&completion{ChatId: testChat + 1, Mode: "main", Deck: "k1", DeckKey: "k1", Name: "덱1"}).Error; err != nil {
+		t.Errorf("same deck of other chat should be saved. %v", err)
+	}
+}
+
+// chat 구분 전 기록이 있는 db는 옮길 chat이 설정되어야 migration한다.
+func TestSqliteChatMigrationWithoutChat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := gorm.Open(sqlite.Open(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE `completions` (`id` integer PRIMARY KEY AUTOINCREMENT,`mode` text NOT NULL DEFAULT '',`season` text NOT NULL DEFAULT '',`deck_key` text NOT NULL DEFAULT '',`name` text,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime)",
+		"CREATE TABLE `mode_seasons` (`mode` text,`name` text,PRIMARY KEY (`mode`))",
+		"INSERT INTO completions (mode, season, deck_key, name) VALUES ('main', '14', 'k1', '덱1')",
+		"INSERT INTO mode_seasons (mode, name) VALUES ('main', '14')",
+	} {
+		if err := legacy.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	sqlDB, _ := legacy.DB()
+	sqlDB.Close()
+
+	_, err = NewStorage(NewSqliteStorageConfig(path))
+	if err == nil || !strings.Contains(err.Error(), "chatId") {
+		t.Fatalf("migration without chat should fail. got %v", err)
+	}
+
+	s, err := NewStorage(NewSqliteStorageConfig(path).WithChatId(testChat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if season, _ := s.Season(testChat, lolcheBot.MainMode); season != "14" {
+		t.Errorf("season should be moved to the chat. got %q", season)
+	}
+	if decs, _ := s.All(testChat, lolcheBot.MainMode); len(decs) != 1 {
+		t.Errorf("records should be moved to the chat. got %v", decs)
+	}
+}
+
+// mode_seasons를 다시 만드는 중 실패한 db도 다시 실행하면 기록을 잃지 않고 이어서 진행한다.
+func TestRebuildModeSeasonsResume(t *testing.T) {
+
+	for _, tt := range []struct {
+		name  string
+		stmts []string
+	}{
+		{"before rename", []string{
+			"CREATE TABLE `mode_seasons` (`mode` text,`name` text,PRIMARY KEY (`mode`))",
+			"INSERT INTO mode_seasons (mode, name) VALUES ('main', '14')",
+			"CREATE TABLE `mode_seasons_new` (`chat_id` integer,`mode` text,`name` text,PRIMARY KEY (`chat_id`,`mode`))",
+			"INSERT INTO mode_seasons_new (chat_id, mode, name) VALUES (0, 'main', '13')",
+		}},
+		{"after rename", []string{
+			"CREATE TABLE `mode_seasons` (`chat_id` integer,`mode` text,`name` text,PRIMARY KEY (`chat_id`,`mode`))",
+			"INSERT INTO mode_seasons (chat_id, mode, name) VALUES (0, 'main', '14')",
+			"CREATE TABLE `mode_seasons_old` (`mode` text,`name` text,PRIMARY KEY (`mode`))",
+			"INSERT INTO mode_seasons_old (mode, name) VALUES ('main', '13')",
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "legacy.db")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, stmt := range tt.stmts {
+				if err := db.Exec(stmt).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := rebuildModeSeasons(db); err != nil {
+				t.Fatal(err)
+			}
+			var seasons []modeSeason
+			if err := db.Find(&seasons).Error; err != nil {
+				t.Fatal(err)
+			}
+			if len(seasons) != 1 || seasons[0] != (modeSeason{Mode: "main", Name: "14"}) {
+				t.Errorf("season should be kept. got %+v", seasons)
+			}
+			for _, table := range []string{"mode_seasons_new", "mode_seasons_old"} {
+				if db.Migrator().HasTable(table) {
+					t.Errorf("%s should be dropped", table)
+				}
+			}
+		})
+	}
+}
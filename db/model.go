@@ -1,20 +1,150 @@
 package db
 
-import "gorm.io/gorm"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// completion은 완료 기록. (chat_id, mode, season, deck) unique index는 기존 중복 기록을 정리한 뒤 migration에서 만든다.
+type completion struct {
+	ID      uint
+	ChatId  int64  `gorm:"not null;default:0;index:idx_completion_chat_mode_season"`
+	Mode    string `gorm:"size:32;not null;default:'';index:idx_completion_chat_mode_season"`
+	Season  string `gorm:"size:64;not null;default:'';index:idx_completion_chat_mode_season"` // 기록한 시즌. 시즌 도입 전 기록은 빈 값
+	Deck    string `gorm:"size:255;not null;default:''"`                                      // 중복 확인용 덱 식별값. deckId 참고
+	DeckKey string `gorm:"not null;default:''"`                                               // lolchess.gg teamBuilderKey. key 도입 전 기록은 빈 값
+	Name    string
+	gorm.Model
+}
+
+// deckId는 완료 기록의 덱 식별값. key가 있으면 key, 없으면 이름
+func deckId(key string, name string) string {
+	if key != "" {
+		return key
+	}
+	return name
+}
+
+// main, pbe는 모드별 table로 완료 기록을 관리하던 schema. 기존 db의 기록을 completions로 옮길 때만 사용한다.
 type main struct {
-	ID   uint
-	Name string
+	ID      uint
+	Season  string `gorm:"not null;default:''"`
+	DeckKey string `gorm:"not null;default:''"`
+	Name    string
 	gorm.Model
 }
 
 type pbe struct {
-	ID   uint
-	Name string
+	ID      uint
+	Season  string `gorm:"not null;default:''"`
+	DeckKey string `gorm:"not null;default:''"`
+	Name    string
 	gorm.Model
 }
 
+// mode는 chat별 현재 모드
 type mode struct {
 	ID     uint
-	IsMain bool
+	ChatId int64  `gorm:"not null;default:0;index"`
+	Name   string `gorm:"size:32;not null;default:''"`
+}
+
+// modeSeason은 chat, 모드별 현재 시즌
+type modeSeason struct {
+	ChatId int64  `gorm:"primaryKey;autoIncrement:false"`
+	Mode   string `gorm:"size:32;primaryKey"`
+	Name   string
+}
+
+type strategy struct {
+	ChatId int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name   string
+}
+
+// metaSnapshot은 크롤링한 meta 기록. Decks는 Position 순서
+type metaSnapshot struct {
+	ID      uint
+	Mode    string `gorm:"size:32;not null;default:'';index:idx_meta_snapshot_mode_taken"`
+	Patch   string
+	TakenAt time.Time `gorm:"index:idx_meta_snapshot_mode_taken"`
+	Decks   []metaSnapshotDeck
+}
+
+type metaSnapshotDeck struct {
+	ID             uint
+	MetaSnapshotID uint `gorm:"index"`
+	Position       int
+	DeckKey        string
+	Name           string
+	Tier           string
+}
+
+// selection은 추천 덱 선택 기록
+type selection struct {
+	ID         uint
+	ChatId     int64  `gorm:"not null;default:0;index:idx_selection_chat_mode"`
+	Mode       string `gorm:"size:32;not null;default:'';index:idx_selection_chat_mode"`
+	DeckKey    string `gorm:"size:64;not null;default:''"` // lolchess.gg teamBuilderKey. key 도입 전 기록은 migration 10에서 이름으로 찾아 채운다
+	Name       string
+	SelectedAt time.Time
+}
+
+// attempt는 덱으로 한 게임의 등수 기록
+type attempt struct {
+	ID        uint
+	ChatId    int64  `gorm:"not null;default:0;index:idx_attempt_chat_mode_season"`
+	Mode      string `gorm:"size:32;not null;default:'';index:idx_attempt_chat_mode_season"`
+	Season    string `gorm:"size:64;not null;default:'';index:idx_attempt_chat_mode_season"`
+	DeckKey   string
+	Name      string
+	Placement int
+	PlayedAt  time.Time
+}
+
+// resetBackup은 /reset으로 삭제한 완료 기록
+type resetBackup struct {
+	ID        uint
+	ChatId    int64  `gorm:"not null;default:0"`
+	Mode      string `gorm:"size:32;not null;default:''"`
+	Season    string `gorm:"size:64;not null;default:''"`
+	CreatedAt time.Time
+	Decks     []resetBackupDeck
+}
+
+type resetBackupDeck struct {
+	ID            uint
+	ResetBackupID uint `gorm:"index"`
+	DeckKey       string
+	Name          string
+	CompletedAt   time.Time
+}
+
+// pending은 버튼을 눌렀을 때 처리할 대상. lolcheBot.Pending 참고
+type pending struct {
+	ChatId    int64  `gorm:"primaryKey;autoIncrement:false"`
+	Kind      string `gorm:"size:16;primaryKey"`
+	Mode      string `gorm:"size:32;primaryKey"`
+	PendingId string `gorm:"size:255;primaryKey"`
+	DeckKey   string
+	Name      string
+	Expires   time.Time
+}
+
+// grant는 /grant로 부여한 권한. SubjectId는 user id 또는 chat id
+type grant struct {
+	SubjectId int64  `gorm:"primaryKey;autoIncrement:false"`
+	Role      string `gorm:"size:16;not null;default:''"`
+	GrantedAt time.Time
+}
+
+// schemaVersion은 적용한 schema migration 기록. 가장 큰 Version이 현재 schema version
+type schemaVersion struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaVersion) TableName() string {
+	return "schema_version"
 }
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteDialector는 cgo 없이 동작하는 sqlite driver를 사용한다. (라즈베리파이 등 크로스 컴파일 환경 고려)
+func sqliteDialector(path string) (gorm.Dialector, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite 파일 경로 미설정")
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("sqlite 디렉토리 생성 실패. %w", err)
+		}
+	}
+
+	return sqlite.Open(path), nil
+}
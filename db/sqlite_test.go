@@ -0,0 +1,258 @@
+package db
+
+import (
+	"lolcheBot"
+	"lolcheBot/db/dbtest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// testChat은 db package test에서 기록하는 chat. 기존 db의 기록도 이 chat으로 옮긴다.
+const testChat int64 = 100
+
+func TestSqliteStorage(t *testing.T) {
+
+	s, err := NewStorage(NewSqliteStorageConfig(filepath.Join(t.TempDir(), "lolche.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("default mode", func(t *testing.T) {
+		if m := mustMode(t, s); m != lolcheBot.MainMode {
+			t.Errorf("default mode should be main. got %s", m.Str())
+		}
+		s.SaveMode(testChat, lolcheBot.PbeMode)
+		if m := mustMode(t, s); m != lolcheBot.PbeMode {
+			t.Errorf("mode not saved. got %s", m.Str())
+		}
+		s.SaveMode(testChat, lolcheBot.MainMode)
+	})
+
+	t.Run("save and delete", func(t *testing.T) {
+		s.Save(testChat, lolcheBot.MainMode, "k1", "덱1")
+		s.Save(testChat, lolcheBot.MainMode, "k1", "덱1")
+		s.Save(testChat, lolcheBot.PbeMode, "k2", "덱2")
+
+		decs, err := s.All(testChat, lolcheBot.MainMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decs) != 1 || decs[0].Name != "덱1" {
+			t.Errorf("unexpected main decks %v", decs)
+		}
+
+		s.DeleteByName(testChat, lolcheBot.MainMode, "덱1")
+		s.DeleteAll(testChat, lolcheBot.PbeMode)
+		decs, _ = s.All(testChat, lolcheBot.MainMode)
+		pbes, _ := s.All(testChat, lolcheBot.PbeMode)
+		if len(decs) != 0 || len(pbes) != 0 {
+			t.Errorf("decks not deleted. main %v pbe %v", decs, pbes)
+		}
+	})
+
+	t.Run("reopen keeps data", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "reopen.db")
+		s1, err := NewStorage(NewSqliteStorageConfig(path).WithChatId(testChat))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s1.Save(testChat, lolcheBot.PbeMode, "", "덱3")
+		s1.SaveMode(testChat, lolcheBot.PbeMode)
+
+		s2, err := NewStorage(NewSqliteStorageConfig(path).WithChatId(testChat))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mustMode(t, s2) != lolcheBot.PbeMode {
+			t.Errorf("mode not persisted")
+		}
+		if decs, _ := s2.All(testChat, lolcheBot.PbeMode); len(decs) != 1 {
+			t.Errorf("decks not persisted %v", decs)
+		}
+	})
+}
+
+func TestSqliteStorageContract(t *testing.T) {
+	dbtest.RunStorageContract(t, func(t *testing.T) lolcheBot.Stoage {
+		s, err := NewStorage(NewSqliteStorageConfig(filepath.Join(t.TempDir(), "lolche.db")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+// SQLite는 시각을 문자열로 비교하므로 선택 시각이 local time으로 저장되면 순서가 틀어진다.
+func TestSqliteSelectionUtc(t *testing.T) {
+
+	local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	s, err := NewStorage(NewSqliteStorageConfig(filepath.Join(t.TempDir(), "lolche.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().Add(-time.Hour).UTC()
+	if err := s.db.Create(&selection{ChatId: testChat, Mode: string(lolcheBot.MainMode), DeckKey: "k1", Name: "덱1", SelectedAt: before}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveSelection(testChat, lolcheBot.MainMode, "k1", "덱1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if last, err := s.LastAttempted(testChat, lolcheBot.MainMode); err != nil || !last["k1"].After(before) {
+		t.Errorf("latest selection should win. got %v %v", last, err)
+	}
+}
+
+// key 도입 전 스키마(deck_key 없음)로 생성된 db도 기동 시 column이 추가되어야 한다.
+func TestSqliteLegacySchema(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := gorm.Open(sqlite.Open(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE mains (id integer PRIMARY KEY AUTOINCREMENT, name text, created_at datetime, updated_at datetime, deleted_at datetime)",
+		"CREATE TABLE pbes (id integer PRIMARY KEY AUTOINCREMENT, name text, created_at datetime, updated_at datetime, deleted_at datetime)",
+		"CREATE TABLE modes (id integer PRIMARY KEY AUTOINCREMENT, is_main numeric)",
+		"INSERT INTO modes (is_main) VALUES (false)",
+		"INSERT INTO mains (name) VALUES ('덱 1')",
+	} {
+		if err := legacy.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	sqlDB, _ := legacy.DB()
+	sqlDB.Close()
+
+	s, err := NewStorage(NewSqliteStorageConfig(path).WithChatId(testChat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mustMode(t, s) != lolcheBot.PbeMode {
+		t.Errorf("existing mode should be kept")
+	}
+
+	decs, err := s.All(testChat, lolcheBot.MainMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decs) != 1 || decs[0] != (lolcheBot.DoneDeck{Name: "덱 1"}) {
+		t.Errorf("legacy record should be loaded without key. got %v", decs)
+	}
+
+	if err := s.Relink(testChat, lolcheBot.MainMode, "덱 1", "k1", "덱1"); err != nil {
+		t.Fatal(err)
+	}
+	decs, _ = s.All(testChat, lolcheBot.MainMode)
+	if len(decs) != 1 || decs[0] != (lolcheBot.DoneDeck{Key: "k1", Name: "덱1"}) {
+		t.Errorf("legacy record should be relinked. got %v", decs)
+	}
+}
+
+// 모드를 is_main으로 구분하던 db는 기동 시 모드 이름 기반 schema로 옮겨져야 한다.
+func TestSqliteModeMigration(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := gorm.Open(sqlite.Open(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE `mains` (`id` integer PRIMARY KEY AUTOINCREMENT,`season` text NOT NULL DEFAULT '',`deck_key` text NOT NULL DEFAULT '',`name` text,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime)",
+		"CREATE TABLE `pbes` (`id` integer PRIMARY KEY AUTOINCREMENT,`season` text NOT NULL DEFAULT '',`deck_key` text NOT NULL DEFAULT '',`name` text,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime)",
+		"CREATE TABLE `modes` (`id` integer PRIMARY KEY AUTOINCREMENT,`is_main` numeric)",
+		"CREATE TABLE `seasons` (`is_main` numeric PRIMARY KEY,`name` text)",
+		"CREATE TABLE `selections` (`id` integer PRIMARY KEY AUTOINCREMENT,`is_main` numeric,`name` text,`selected_at` datetime)",
+		"CREATE TABLE `meta_snapshots` (`id` integer PRIMARY KEY AUTOINCREMENT,`is_main` numeric,`patch` text,`taken_at` datetime)",
+		"CREATE INDEX idx_meta_snapshot_taken ON meta_snapshots(is_main, taken_at)",
+		"INSERT INTO modes (is_main) VALUES (false)",
+		"INSERT INTO seasons (is_main, name) VALUES (true, '14'), (false, '15')",
+		"INSERT INTO mains (season, deck_key, name) VALUES ('14', 'k1', '덱1'), ('', '', '덱2')",
+		"INSERT INTO mains (season, deck_key, name, deleted_at) VALUES ('14', 'k3', '덱3', '2025-01-01 00:00:00+00:00')",
+		"INSERT INTO pbes (season, deck_key, name) VALUES ('15', 'k4', '덱4')",
+		"INSERT INTO selections (is_main, name, selected_at) VALUES (true, '덱1', '2025-01-01 00:00:00+00:00')",
+		"INSERT INTO meta_snapshots (is_main, patch, taken_at) VALUES (false, '15.5', '2025-01-01 00:00:00+00:00')",
+	} {
+		if err := legacy.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	sqlDB, _ := legacy.DB()
+	sqlDB.Close()
+
+	for i := 0; i < 2; i++ { // 두 번째 기동에서는 옮길 것이 없어야 한다.
+		s, err := NewStorage(NewSqliteStorageConfig(path).WithChatId(testChat))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m := mustMode(t, s); m != lolcheBot.PbeMode {
+			t.Errorf("expected pbe mode. got %s", m)
+		}
+		if main, _ := s.Season(testChat, lolcheBot.MainMode); main != "14" {
+			t.Errorf("expected main season 14. got %q", main)
+		}
+		if pbe, _ := s.Season(testChat, lolcheBot.PbeMode); pbe != "15" {
+			t.Errorf("expected pbe season 15. got %q", pbe)
+		}
+
+		if decs, _ := s.All(testChat, lolcheBot.MainMode); len(decs) != 1 || decs[0] != (lolcheBot.DoneDeck{Key: "k1", Name: "덱1"}) {
+			t.Errorf("unexpected main records %v", decs)
+		}
+		if decs, _ := s.AllInSeason(testChat, lolcheBot.MainMode, ""); len(decs) != 1 || decs[0].Name != "덱2" {
+			t.Errorf("unexpected main records without season %v", decs)
+		}
+		if decs, _ := s.All(testChat, lolcheBot.PbeMode); len(decs) != 1 || decs[0].Name != "덱4" {
+			t.Errorf("unexpected pbe records %v", decs)
+		}
+		if decs, _ := s.All(testChat+1, lolcheBot.MainMode); len(decs) != 0 {
+			t.Errorf("records should be moved only to the configured chat. got %v", decs)
+		}
+		if m, _ := s.Mode(testChat + 1); m != lolcheBot.MainMode {
+			t.Errorf("other chat should start with main mode. got %s", m)
+		}
+		var cnt int64
+		s.db.Unscoped().Model(&completion{}).Count(&cnt)
+		if cnt != 4 {
+			t.Errorf("deleted records should be moved too. got %d", cnt)
+		}
+
+		if last, _ := s.LastAttempted(testChat, lolcheBot.MainMode); len(last) != 1 || last["k1"].IsZero() {
+			t.Errorf("selection should be kept with the key of the same name. got %v", last)
+		}
+		if snap, ok, _ := s.MetaSnapshotAt(lolcheBot.PbeMode, time.Now()); !ok || snap.Patch != "15.5" {
+			t.Errorf("meta snapshot should be kept. got %+v", snap)
+		}
+
+		for _, table := range []string{"mains", "pbes", "seasons"} {
+			if s.db.Migrator().HasTable(table) {
+				t.Errorf("%s should be dropped", table)
+			}
+		}
+		for _, model := range []any{&mode{}, &selection{}, &metaSnapshot{}} {
+			if s.db.Migrator().HasColumn(model, "is_main") {
+				t.Errorf("is_main of %T should be dropped", model)
+			}
+		}
+
+		sqlDB, _ := s.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func mustMode(t *testing.T, s *Storage) lolcheBot.Mode {
+	t.Helper()
+	m, err := s.Mode(testChat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -0,0 +1,117 @@
+package lolcheBot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyRecorder는 Meta 조회에 성공할 때마다 결과를 meta 기록으로 저장하는 DeckCrawler.
+// snapshot으로 대체된 결과는 저장하지 않고, 직전 기록과 같은 목록은 중복 저장하지 않는다.
+type historyRecorder struct {
+	DeckCrawler
+	stg Stoage
+	now func() time.Time
+
+	mu   sync.Mutex
+	last map[Mode]string
+}
+
+func newHistoryRecorder(dc DeckCrawler, stg Stoage) *historyRecorder {
+	return &historyRecorder{
+		DeckCrawler: dc,
+		stg:         stg,
+		now:         time.Now,
+		last:        make(map[Mode]string),
+	}
+}
+
+func (h *historyRecorder) Meta(mode Mode) ([]Deck, error) {
+	decLi, err := h.DeckCrawler.Meta(mode)
+	if err != nil {
+		return nil, err
+	}
+	if _, stale := h.Stale(mode); stale {
+		return decLi, nil
+	}
+
+	snap := MetaSnapshot{
+		Patch:   h.Patch(mode),
+		TakenAt: h.now(),
+		Decks:   decLi,
+	}
+	sig := snapshotSig(&snap)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.last[mode] == sig {
+		return decLi, nil
+	}
+	if err := h.stg.SaveMetaSnapshot(mode, snap); err != nil {
+		log.Printf("%s meta 기록 저장 실패. %s", mode.Str(), err.Error())
+		return decLi, nil
+	}
+	h.last[mode] = sig
+	return decLi, nil
+}
+
+// snapshotSig는 기록 중복 비교용 문자열. 패치와 덱 순서, 티어가 같으면 같은 기록으로 본다.
+func snapshotSig(snap *MetaSnapshot) string {
+	var sb strings.Builder
+	sb.WriteString(snap.Patch)
+	for _, deck := range snap.Decks {
+		sb.WriteString("\n" + deck.Key + "\t" + deck.Name + "\t" + deck.Tier)
+	}
+	return sb.String()
+}
+
+func (t TeleBot) historyJob(arg string) {
+	if arg == "" {
+		t.SendMessage("조회할 날짜 필요. ex) /history 2025-03-01")
+		return
+	}
+	day, err := time.ParseInLocation(time.DateOnly, arg, time.Local)
+	if err != nil {
+		t.SendMessage("잘못된 날짜 형식. ex) /history 2025-03-01")
+		return
+	}
+
+	mode, ok := t.currentMode()
+	if !ok {
+		return
+	}
+	snap, ok, err := t.stg.MetaSnapshotAt(mode, day.AddDate(0, 0, 1).Add(-time.Nanosecond))
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("meta 기록 조회 오류 발생. %s", err.Error()))
+		return
+	}
+	if !ok {
+		t.SendMessage(fmt.Sprintf("%s %s 이전 meta 기록이 없습니다.", mode.Str(), arg))
+		return
+	}
+	t.SendMessage(historyText(mode, &snap))
+}
+
+// historyText는 meta 기록 문구. ex)
+//
+//	[정규 모드] 03/01 14:05 기준 meta (패치 15.4)
+//	1. [S] 덱1
+//	2. [A] 덱2
+func historyText(mode Mode, snap *MetaSnapshot) string {
+	title := fmt.Sprintf("[%s] %s 기준 meta", mode.Str(), snap.TakenAt.Format("2006/01/02 15:04"))
+	if snap.Patch != "" {
+		title += fmt.Sprintf(" (패치 %s)", snap.Patch)
+	}
+
+	lines := []string{title}
+	for i, deck := range snap.Decks {
+		name := deck.Name
+		if deck.Tier != "" {
+			name = fmt.Sprintf("[%s] %s", deck.Tier, name)
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, name))
+	}
+	return strings.Join(lines, "\n")
+}
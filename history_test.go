@@ -0,0 +1,76 @@
+package lolcheBot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHistoryRecorder(t *testing.T) {
+
+	decks := []Deck{{Key: "k1", Name: "덱1", Tier: "S"}}
+	changed := []Deck{{Key: "k1", Name: "덱1", Tier: "A"}}
+	dc := NewScriptedCrawler().
+		Script(MainMode,
+			ScriptResponse{Decks: decks, Patch: "15.4"},
+			ScriptResponse{Decks: decks, Patch: "15.4"},
+			ScriptResponse{Decks: changed, Patch: "15.4", Stale: time.Now()},
+			ScriptResponse{Decks: changed, Patch: "15.4"},
+			ScriptResponse{Decks: changed, Patch: "15.4"},
+			ScriptResponse{Err: errors.New("site down")},
+		).
+		Script(PbeMode, ScriptResponse{Decks: decks, Patch: "15.4"})
+	stg := &failingStorage{MemoryStorage: NewMemoryStorage()}
+	h := newHistoryRecorder(dc, stg)
+
+	for i := 0; i < 2; i++ {
+		if _, err := h.Meta(MainMode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if saved := stg.snapshots[MainMode]; len(saved) != 1 || saved[0].Patch != "15.4" || saved[0].Decks[0].Name != "덱1" {
+		t.Fatalf("same meta should be saved once. got %+v", saved)
+	}
+
+	h.Meta(PbeMode)
+	if len(stg.snapshots[PbeMode]) != 1 {
+		t.Errorf("modes should be recorded separately. got %d", len(stg.snapshots[PbeMode]))
+	}
+
+	h.Meta(MainMode)
+	if len(stg.snapshots[MainMode]) != 1 {
+		t.Errorf("stale meta should not be saved. got %d", len(stg.snapshots[MainMode]))
+	}
+
+	stg.err = errors.New("db down")
+	if decLi, err := h.Meta(MainMode); err != nil || len(decLi) != 1 {
+		t.Errorf("save failure should not fail Meta. got %v %v", decLi, err)
+	}
+	stg.err = nil
+	h.Meta(MainMode)
+	if saved := stg.snapshots[MainMode]; len(saved) != 2 || saved[1].Decks[0].Tier != "A" {
+		t.Errorf("changed tier should be saved after failure. got %+v", saved)
+	}
+
+	if _, err := h.Meta(MainMode); err == nil || len(stg.snapshots[MainMode]) != 2 {
+		t.Errorf("failed Meta should not be saved. got %v", err)
+	}
+}
+
+func TestHistoryText(t *testing.T) {
+
+	snap := MetaSnapshot{
+		Patch:   "15.4",
+		TakenAt: time.Date(2025, 3, 1, 14, 5, 0, 0, time.Local),
+		Decks:   []Deck{{Name: "덱1", Tier: "S"}, {Name: "덱2"}},
+	}
+	want := "[정규 모드] 2025/03/01 14:05 기준 meta (패치 15.4)\n1. [S] 덱1\n2. 덱2"
+	if got := historyText(MainMode, &snap); got != want {
+		t.Errorf("unexpected text\n%s", got)
+	}
+
+	snap.Patch = ""
+	if got := historyText(PbeMode, &snap); got != "[pbe 모드] 2025/03/01 14:05 기준 meta\n1. [S] 덱1\n2. 덱2" {
+		t.Errorf("unexpected text\n%s", got)
+	}
+}
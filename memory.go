@@ -0,0 +1,387 @@
+package lolcheBot
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStorage는 프로세스 메모리에만 기록하는 Stoage 구현체. 재기동 시 기록이 사라지므로 테스트나 임시 실행 용도.
+type MemoryStorage struct {
+	mu         sync.Mutex
+	decs       map[seasonKey][]DoneDeck
+	seasons    map[chatMode]string
+	modes      map[int64]Mode
+	strategies map[int64]string
+	selections map[chatMode]map[string]time.Time // 덱 key별 마지막 선택 시각
+	snapshots  map[Mode][]MetaSnapshot
+	attempts   map[seasonKey][]Attempt
+	backups    map[int64]memoryBackup
+	pendings   map[pendingKey]Pending
+	roles      map[int64]Role
+}
+
+type pendingKey struct {
+	chatId int64
+	kind   PendingKind
+	mode   Mode
+	id     string
+}
+
+type memoryBackup struct {
+	key  seasonKey
+	decs []DoneDeck
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		decs:       make(map[seasonKey][]DoneDeck),
+		seasons:    make(map[chatMode]string),
+		modes:      make(map[int64]Mode),
+		strategies: make(map[int64]string),
+		selections: make(map[chatMode]map[string]time.Time),
+		snapshots:  make(map[Mode][]MetaSnapshot),
+		attempts:   make(map[seasonKey][]Attempt),
+		backups:    make(map[int64]memoryBackup),
+		pendings:   make(map[pendingKey]Pending),
+		roles:      make(map[int64]Role),
+	}
+}
+
+type chatMode struct {
+	chatId int64
+	mode   Mode
+}
+
+type seasonKey struct {
+	chatId int64
+	mode   Mode
+	season string
+}
+
+// current는 chat, mode의 현재 시즌 key. s.mu를 잡은 상태에서 호출해야 한다.
+func (s *MemoryStorage) current(chatId int64, mode Mode) seasonKey {
+	return seasonKey{chatId: chatId, mode: mode, season: s.seasons[chatMode{chatId, mode}]}
+}
+
+func (s *MemoryStorage) Save(chatId int64, mode Mode, key string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.save(s.current(chatId, mode), key, name)
+	return nil
+}
+
+// save는 Save와 같은 규칙으로 cur 시즌에 기록한다. s.mu를 잡은 상태에서 호출해야 한다.
+func (s *MemoryStorage) save(cur seasonKey, key string, name string) {
+	decs := s.decs[cur]
+	if key != "" {
+		for _, d := range decs {
+			if d.Key == key {
+				return
+			}
+		}
+		linked := false
+		for i, d := range decs {
+			if d.Key == "" && d.Name == name {
+				decs[i].Key = key
+				linked = true
+			}
+		}
+		if linked {
+			return
+		}
+	} else {
+		for _, d := range decs {
+			if d.Name == name {
+				return
+			}
+		}
+	}
+	s.decs[cur] = append(decs, DoneDeck{Key: key, Name: name})
+}
+
+func (s *MemoryStorage) Relink(chatId int64, mode Mode, name string, key string, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := s.current(chatId, mode)
+	linked := slices.ContainsFunc(s.decs[cur], func(d DoneDeck) bool { return d.Key == key })
+	decs := s.decs[cur][:0]
+	for _, d := range s.decs[cur] {
+		if d.Key == "" && d.Name == name {
+			if linked { // key 기록이 이미 있으면 key 없는 기록은 합친다.
+				continue
+			}
+			d = DoneDeck{Key: key, Name: newName}
+			linked = true
+		}
+		decs = append(decs, d)
+	}
+	s.decs[cur] = decs
+	return nil
+}
+
+func (s *MemoryStorage) DeleteAll(chatId int64, mode Mode) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := s.current(chatId, mode)
+	if len(s.decs[cur]) == 0 {
+		return 0, nil
+	}
+	id := int64(len(s.backups) + 1)
+	s.backups[id] = memoryBackup{key: cur, decs: s.decs[cur]}
+	delete(s.decs, cur)
+	return id, nil
+}
+
+func (s *MemoryStorage) RestoreBackup(chatId int64, mode Mode, id int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backup, ok := s.backups[id]
+	if !ok || backup.key.chatId != chatId || backup.key.mode != mode {
+		return 0, fmt.Errorf("없는 backup. %d", id)
+	}
+	for _, d := range backup.decs {
+		s.save(backup.key, d.Key, d.Name)
+	}
+	return len(backup.decs), nil
+}
+
+func (s *MemoryStorage) Delete(chatId int64, mode Mode, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := s.current(chatId, mode)
+	s.decs[cur] = slices.DeleteFunc(s.decs[cur], func(d DoneDeck) bool {
+		return d.Key == key
+	})
+	return nil
+}
+
+func (s *MemoryStorage) DeleteByName(chatId int64, mode Mode, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := s.current(chatId, mode)
+	s.decs[cur] = slices.DeleteFunc(s.decs[cur], func(d DoneDeck) bool {
+		return d.Name == name
+	})
+	return nil
+}
+
+func (s *MemoryStorage) All(chatId int64, mode Mode) ([]DoneDeck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.decs[s.current(chatId, mode)]), nil
+}
+
+func (s *MemoryStorage) AllInSeason(chatId int64, mode Mode, season string) ([]DoneDeck, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.decs[seasonKey{chatId: chatId, mode: mode, season: season}]), nil
+}
+
+func (s *MemoryStorage) Season(chatId int64, mode Mode) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.seasons[chatMode{chatId, mode}], nil
+}
+
+func (s *MemoryStorage) SaveSeason(chatId int64, mode Mode, season string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seasons[chatMode{chatId, mode}] = season
+	return nil
+}
+
+func (s *MemoryStorage) Seasons(chatId int64, mode Mode) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := []string{s.seasons[chatMode{chatId, mode}]}
+	for key, decs := range s.decs {
+		if key.chatId == chatId && key.mode == mode && len(decs) > 0 && !slices.Contains(names, key.season) {
+			names = append(names, key.season)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (s *MemoryStorage) Mode(chatId int64) (Mode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if mode, ok := s.modes[chatId]; ok {
+		return mode, nil
+	}
+	return MainMode, nil // default 값은 메인모드.
+}
+
+func (s *MemoryStorage) SaveMode(chatId int64, mode Mode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.modes[chatId] = mode
+	return nil
+}
+
+func (s *MemoryStorage) Strategy(chatId int64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.strategies[chatId], nil
+}
+
+func (s *MemoryStorage) SaveStrategy(chatId int64, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.strategies[chatId] = name
+	return nil
+}
+
+func (s *MemoryStorage) SaveSelection(chatId int64, mode Mode, key string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cm := chatMode{chatId, mode}
+	if s.selections[cm] == nil {
+		s.selections[cm] = make(map[string]time.Time)
+	}
+	s.selections[cm][key] = time.Now()
+	return nil
+}
+
+func (s *MemoryStorage) LastAttempted(chatId int64, mode Mode) (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rtn := make(map[string]time.Time)
+	maps.Copy(rtn, s.selections[chatMode{chatId, mode}])
+	for key, attempts := range s.attempts {
+		if key.chatId != chatId || key.mode != mode {
+			continue
+		}
+		for _, a := range attempts {
+			if a.PlayedAt.After(rtn[a.Key]) {
+				rtn[a.Key] = a.PlayedAt
+			}
+		}
+	}
+	return rtn, nil
+}
+
+func (s *MemoryStorage) SaveMetaSnapshot(mode Mode, snap MetaSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	decks := make([]Deck, len(snap.Decks))
+	for i, deck := range snap.Decks {
+		decks[i] = Deck{Key: deck.Key, Name: deck.Name, Tier: deck.Tier}
+	}
+	snap.Decks = decks
+	s.snapshots[mode] = append(s.snapshots[mode], snap)
+	return nil
+}
+
+func (s *MemoryStorage) MetaSnapshotAt(mode Mode, at time.Time) (MetaSnapshot, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rtn MetaSnapshot
+	found := false
+	for _, snap := range s.snapshots[mode] {
+		if !snap.TakenAt.After(at) && (!found || snap.TakenAt.After(rtn.TakenAt)) {
+			rtn = snap
+			found = true
+		}
+	}
+	if found {
+		rtn.Decks = slices.Clone(rtn.Decks)
+	}
+	return rtn, found, nil
+}
+
+func (s *MemoryStorage) SaveAttempt(chatId int64, mode Mode, attempt Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := s.current(chatId, mode)
+	s.attempts[cur] = append(s.attempts[cur], attempt)
+	return nil
+}
+
+func (s *MemoryStorage) Attempts(chatId int64, mode Mode) ([]Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := slices.Clone(s.attempts[s.current(chatId, mode)])
+	slices.SortStableFunc(attempts, func(a, b Attempt) int { return a.PlayedAt.Compare(b.PlayedAt) })
+	return attempts, nil
+}
+
+func (s *MemoryStorage) SavePending(chatId int64, pendings []Pending) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(pendings) == 0 {
+		return nil
+	}
+	for key, p := range s.pendings {
+		if key.chatId == chatId && time.Since(p.Expires) > PendingKeep {
+			delete(s.pendings, key)
+		}
+	}
+	for _, p := range pendings {
+		s.pendings[pendingKey{chatId, p.Kind, p.Mode, p.Id}] = p
+	}
+	return nil
+}
+
+func (s *MemoryStorage) Pending(chatId int64, kind PendingKind, mode Mode, id string) (Pending, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pendings[pendingKey{chatId, kind, mode, id}]
+	return p, ok, nil
+}
+
+func (s *MemoryStorage) Role(id int64) (Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.roles[id], nil
+}
+
+func (s *MemoryStorage) Roles() (map[int64]Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return maps.Clone(s.roles), nil
+}
+
+func (s *MemoryStorage) SaveRole(id int64, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.roles[id] = role
+	return nil
+}
+
+func (s *MemoryStorage) DeleteRole(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.roles, id)
+	return nil
+}
@@ -0,0 +1,86 @@
+package lolcheBot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// pendingTTL은 버튼을 보낸 뒤 누를 수 있는 기간
+const pendingTTL = 24 * time.Hour
+
+// pendingCommands는 버튼을 다시 받을 수 있는 명령어
+var pendingCommands = map[PendingKind]string{
+	PendingDeck:   "/update",
+	PendingDone:   "/done",
+	PendingSeason: "/season",
+}
+
+// savePending은 보낼 버튼이 가리키는 대상을 저장한다. Expires가 없으면 pendingTTL 동안 유효하다.
+// 저장에 실패해도 버튼은 보내므로 log만 남긴다.
+func (t TeleBot) savePending(pendings ...Pending) {
+	expires := time.Now().Add(pendingTTL)
+	for i := range pendings {
+		if pendings[i].Expires.IsZero() {
+			pendings[i].Expires = expires
+		}
+	}
+	if err := t.stg.SavePending(t.owner, pendings); err != nil {
+		log.Printf("버튼 정보 저장 실패. %s", err.Error())
+	}
+}
+
+// takePending은 한 번만 누를 수 있는 버튼의 대상을 꺼낸다. 없거나 만료되었으면 false.
+// 버튼을 여러 번 눌러도 한 번만 처리되도록 꺼낸 요청은 만료시킨다. 만료시키지 못하면 처리하지 않는다.
+func (t TeleBot) takePending(kind PendingKind, mode Mode, id string, now time.Time) (Pending, bool, error) {
+	p, found, err := t.stg.Pending(t.owner, kind, mode, id)
+	if err != nil || !found || !now.Before(p.Expires) {
+		return Pending{}, false, err
+	}
+	taken := p
+	taken.Expires = now
+	if err := t.stg.SavePending(t.owner, []Pending{taken}); err != nil {
+		return Pending{}, false, err
+	}
+	return p, true, nil
+}
+
+// newNonce는 보낸 버튼 목록마다 다른 id
+func newNonce() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// pendingId는 버튼이 가리키는 대상의 저장 id. 목록 버튼은 보낸 목록마다 Nonce가 달라서, 다시 보낸 목록이 이전 목록의 같은 위치 버튼을 덮어쓰지 않는다.
+func pendingId(cb callback) string {
+	if cb.Nonce == "" {
+		return cb.Key
+	}
+	return cb.Nonce + "." + cb.Key
+}
+
+// pending은 누른 버튼이 가리키는 대상. 없거나 만료됐으면 안내 메시지를 보내고 false
+func (t TeleBot) pending(kind PendingKind, cb callback) (Pending, bool) {
+	p, found, err := t.stg.Pending(t.owner, kind, cb.Mode, pendingId(cb))
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return Pending{}, false
+	}
+	if msg := pendingError(kind, p, found, time.Now()); msg != "" {
+		t.SendMessage(msg)
+		return Pending{}, false
+	}
+	return p, true
+}
+
+// pendingError는 버튼을 처리할 수 없을 때의 안내 문구. 처리할 수 있으면 빈 문자열
+func pendingError(kind PendingKind, p Pending, found bool, now time.Time) string {
+	cmd := pendingCommands[kind]
+	if !found {
+		return fmt.Sprintf("버튼 정보 없음. %s로 다시 조회 필요", cmd)
+	}
+	if !now.Before(p.Expires) {
+		return fmt.Sprintf("%s 만료된 버튼(유효기간 %d시간). %s로 다시 조회 필요", ago(now.Sub(p.Expires)), int(pendingTTL.Hours()), cmd)
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package lolcheBot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPendingError(t *testing.T) {
+
+	now := time.Now()
+	valid := Pending{Kind: PendingDeck, Expires: now.Add(time.Minute)}
+	expired := Pending{Kind: PendingDeck, Expires: now.Add(-3 * time.Hour)}
+
+	if msg := pendingError(PendingDeck, valid, true, now); msg != "" {
+		t.Errorf("valid pending should have no error. got %q", msg)
+	}
+	if msg := pendingError(PendingDeck, expired, true, now); !strings.Contains(msg, "3시간 전 만료") || !strings.Contains(msg, "/update") {
+		t.Errorf("expired pending should tell when it expired. got %q", msg)
+	}
+	if msg := pendingError(PendingDone, Pending{}, false, now); !strings.Contains(msg, "정보 없음") || !strings.Contains(msg, "/done") {
+		t.Errorf("missing pending should point to the command. got %q", msg)
+	}
+}
+
+// failingStorage는 err가 있으면 SavePending, SaveMetaSnapshot이 실패하는 MemoryStorage
+type failingStorage struct {
+	*MemoryStorage
+	err error
+}
+
+func (f *failingStorage) SavePending(chatId int64, pendings []Pending) error {
+	if f.err != nil {
+		return f.err
+	}
+	return f.MemoryStorage.SavePending(chatId, pendings)
+}
+
+func (f *failingStorage) SaveMetaSnapshot(mode Mode, snap MetaSnapshot) error {
+	if f.err != nil {
+		return f.err
+	}
+	return f.MemoryStorage.SaveMetaSnapshot(mode, snap)
+}
+
+func TestTakePending(t *testing.T) {
+
+	now := time.Now()
+	stg := &failingStorage{MemoryStorage: NewMemoryStorage()}
+	stg.SavePending(1, []Pending{
+		{Kind: PendingReset, Mode: MainMode, Id: "a", Expires: now.Add(time.Minute)},
+		{Kind: PendingReset, Mode: PbeMode, Id: "b", Expires: now.Add(-time.Second)},
+		{Kind: PendingUndo, Mode: MainMode, Id: "7", Expires: now.Add(time.Minute)},
+	})
+	bot := TeleBot{stg: stg, owner: 1}
+
+	if _, ok, _ := (TeleBot{stg: stg, owner: 2}).takePending(PendingReset, MainMode, "a", now); ok {
+		t.Error("request of other chat should not be taken")
+	}
+	if p, ok, err := bot.takePending(PendingReset, MainMode, "a", now); !ok || err != nil || p.Mode != MainMode {
+		t.Errorf("valid request should be taken. got %+v %v %v", p, ok, err)
+	}
+	if _, ok, _ := bot.takePending(PendingReset, MainMode, "a", now); ok {
+		t.Error("request should be taken only once")
+	}
+	if _, ok, _ := bot.takePending(PendingReset, PbeMode, "b", now); ok {
+		t.Error("expired request should not be taken")
+	}
+	if _, ok, _ := bot.takePending(PendingUndo, MainMode, "a", now); ok {
+		t.Error("request of other kind should not be taken")
+	}
+
+	// 만료시키지 못하면 두 번 처리될 수 있으므로 꺼내지 않는다
+	stg.err = errors.New("db down")
+	if _, ok, err := bot.takePending(PendingUndo, MainMode, "7", now); ok || err == nil {
+		t.Errorf("request should not be taken when it cannot be expired. got %v %v", ok, err)
+	}
+	stg.err = nil
+	if p, ok, _ := bot.takePending(PendingUndo, MainMode, "7", now); !ok || p.Id != "7" {
+		t.Errorf("undo request should be taken once it can be expired. got %+v %v", p, ok)
+	}
+}
+
+// 다시 보낸 목록의 버튼이 이전 목록의 같은 위치 버튼을 덮어쓰지 않아야 한다.
+func TestPendingIdPerList(t *testing.T) {
+
+	stg := NewMemoryStorage()
+	bot := TeleBot{stg: stg, owner: 1}
+
+	first := callback{Action: actRestore, Mode: MainMode, Key: "1", Nonce: "a"}
+	second := callback{Action: actRestore, Mode: MainMode, Key: "1", Nonce: "b"}
+	expires := time.Now().Add(time.Hour)
+	stg.SavePending(1, []Pending{{Kind: PendingDone, Mode: MainMode, Id: pendingId(first), Name: "덱1", Expires: expires}})
+	stg.SavePending(1, []Pending{{Kind: PendingDone, Mode: MainMode, Id: pendingId(second), Name: "덱2", Expires: expires}})
+
+	if p, ok, _ := bot.takePending(PendingDone, MainMode, pendingId(first), time.Now()); !ok || p.Name != "덱1" {
+		t.Errorf("old list button should keep its deck. got %+v %v", p, ok)
+	}
+	if p, ok, _ := bot.takePending(PendingDone, MainMode, pendingId(second), time.Now()); !ok || p.Name != "덱2" {
+		t.Errorf("new list button should point to its deck. got %+v %v", p, ok)
+	}
+	if id := pendingId(callback{Action: actSelect, Mode: MainMode, Key: "deck-key"}); id != "deck-key" {
+		t.Errorf("button without nonce should use its key. got %q", id)
+	}
+}
@@ -0,0 +1,87 @@
+package lolcheBot
+
+import (
+	"regexp"
+	"strings"
+)
+
+// doneSet은 decLi 중 완료된 덱의 index 집합. key가 있는 기록은 key로만, key가 없는 기록은 이름으로 매칭한다.
+func doneSet(decLi []Deck, doneLi []DoneDeck) map[int]bool {
+	keys := make(map[string]bool)
+	names := make(map[string]bool)
+	for _, d := range doneLi {
+		if d.Key != "" {
+			keys[d.Key] = true
+		} else {
+			names[d.Name] = true
+		}
+	}
+
+	rtn := make(map[int]bool)
+	for i, d := range decLi {
+		if (d.Key != "" && keys[d.Key]) || names[d.Name] {
+			rtn[i] = true
+		}
+	}
+	return rtn
+}
+
+// reconcile은 key 없이 이름으로만 저장된 완료 기록을 crawl된 덱과 매칭한다. 반환값은 doneLi index별 매칭된 덱.
+// 정확히 같은 이름이거나, 공백/대소문자/접두어([증강] 등) 차이만 있는 이름이 하나의 덱과만 일치할 때만 확실한 매칭으로 본다.
+// 접두어 차이는 양쪽 모두 접두어가 있을 때만 무시한다. [상징] 덱과 접두어 없는 같은 이름의 덱은 다른 덱이다.
+func reconcile(decLi []Deck, doneLi []DoneDeck) map[int]Deck {
+	claimed := make(map[string]bool)
+	for _, d := range doneLi {
+		if d.Key != "" {
+			claimed[d.Key] = true
+		}
+	}
+
+	rtn := make(map[int]Deck)
+	for _, same := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return compactName(a) == compactName(b) },
+		renamedPrefix,
+	} {
+		for i, done := range doneLi {
+			if done.Key != "" {
+				continue
+			}
+			if _, ok := rtn[i]; ok {
+				continue
+			}
+
+			var match *Deck
+			cnt := 0
+			for j := range decLi {
+				if decLi[j].Key != "" && !claimed[decLi[j].Key] && same(decLi[j].Name, done.Name) {
+					match = &decLi[j]
+					cnt++
+				}
+			}
+			if cnt == 1 {
+				rtn[i] = *match
+				claimed[match.Key] = true
+			}
+		}
+	}
+	return rtn
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+var prefix = regexp.MustCompile(`^\[[^\]]*\]`)
+
+// compactName은 공백과 대소문자 차이를 무시한 이름
+func compactName(name string) string {
+	return strings.ToLower(spaces.ReplaceAllString(name, ""))
+}
+
+// baseName은 [증강], [상징] 등의 접두어까지 무시한 이름
+func baseName(name string) string {
+	return compactName(prefix.ReplaceAllString(strings.TrimSpace(name), ""))
+}
+
+// renamedPrefix는 두 이름 모두 접두어가 있고 접두어만 다른지 여부. ex) [증강체] 덱 → [증강] 덱
+func renamedPrefix(a, b string) bool {
+	return prefix.MatchString(strings.TrimSpace(a)) && prefix.MatchString(strings.TrimSpace(b)) && baseName(a) == baseName(b)
+}
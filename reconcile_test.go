@@ -0,0 +1,82 @@
+package lolcheBot
+
+import (
+	"testing"
+)
+
+var reconcileDecks = []Deck{
+	{Key: "k0", Name: "빌지워터 미스 포츈"},
+	{Key: "k1", Name: "[증강] 전략가의 왕관"},
+	{Key: "k2", Name: "[상징] 요들 하이머딩거"},
+	{Key: "k3", Name: "요들 하이머딩거"},
+	{Key: "k4", Name: "공허 카사딘"},
+	{Key: "k5", Name: "[상징] 공허 카사딘"},
+	{Key: "k6", Name: "[증강] 요들 하이머딩거"},
+}
+
+func TestReconcile(t *testing.T) {
+
+	doneLi := []DoneDeck{
+		{Name: "빌지워터 미스 포츈"},           // 0: 이름 그대로
+		{Name: "[증강]전략가의  왕관"},         // 1: 공백 차이
+		{Name: "[증강체] 공허 카사딘"},         // 2: 접두어 차이. 접두어 없는 k4가 아니라 k5
+		{Name: "[상징]요들 하이머딩거"},         // 3: 공백 차이. 접두어 무시 시 k6과도 같지만 공백 단계에서 먼저 확정
+		{Name: "사라진 덱"},                // 4: 매칭 없음
+		{Key: "k9", Name: "이미 key 있음"}, // 5: 대상 아님
+	}
+
+	links := reconcile(reconcileDecks, doneLi)
+
+	want := map[int]string{0: "k0", 1: "k1", 2: "k5", 3: "k2"}
+	if len(links) != len(want) {
+		t.Errorf("expected %d links. got %v", len(want), links)
+	}
+	for i, key := range want {
+		if links[i].Key != key {
+			t.Errorf("done[%d] %s should link to %s. got %+v", i, doneLi[i].Name, key, links[i])
+		}
+	}
+}
+
+func TestReconcileAmbiguous(t *testing.T) {
+
+	// 접두어를 무시하면 k2, k6 두 덱과 일치하므로 확실한 매칭이 아님
+	links := reconcile(reconcileDecks, []DoneDeck{{Name: "[증강체] 요들 하이머딩거"}})
+	if len(links) != 0 {
+		t.Errorf("ambiguous name should not be linked. got %v", links)
+	}
+
+	// 이미 key로 완료된 덱은 다른 기록에 다시 연결하지 않는다
+	links = reconcile(reconcileDecks, []DoneDeck{{Key: "k4", Name: "공허 카사딘"}, {Name: "공허  카사딘"}})
+	if len(links) != 0 {
+		t.Errorf("claimed deck should not be linked again. got %v", links)
+	}
+}
+
+// 접두어가 있는 덱과 없는 덱은 이름이 같아도 다른 덱
+func TestReconcilePrefixRequired(t *testing.T) {
+
+	plain := []Deck{{Key: "k3", Name: "요들 하이머딩거"}}
+	if links := reconcile(plain, []DoneDeck{{Name: "[상징] 요들 하이머딩거"}}); len(links) != 0 {
+		t.Errorf("special deck should not be linked to plain deck. got %v", links)
+	}
+
+	special := []Deck{{Key: "k2", Name: "[상징] 요들 하이머딩거"}}
+	if links := reconcile(special, []DoneDeck{{Name: "요들 하이머딩거"}}); len(links) != 0 {
+		t.Errorf("plain deck should not be linked to special deck. got %v", links)
+	}
+}
+
+func TestDoneSet(t *testing.T) {
+
+	doneLi := []DoneDeck{
+		{Key: "k0", Name: "예전 이름"},
+		{Name: "공허 카사딘"},
+		{Key: "k9", Name: "요들 하이머딩거"},
+	}
+
+	done := doneSet(reconcileDecks, doneLi)
+	if !done[0] || !done[4] || done[3] || len(done) != 2 {
+		t.Errorf("key match first, name match only for records without key. got %v", done)
+	}
+}
@@ -0,0 +1,106 @@
+package lolcheBot
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	resetConfirmTimeout = time.Minute      // 삭제 확인 버튼 유효 시간
+	resetUndoPeriod     = 10 * time.Minute // 삭제 되돌리기 버튼 유효 시간
+)
+
+// resetJob은 현재 시즌 기록 수를 알리고 확인/취소 버튼을 보낸다. 확인을 눌러야 삭제한다.
+func (t TeleBot) resetJob() {
+	mode, ok := t.currentMode()
+	if !ok {
+		return
+	}
+	doneLi, err := t.stg.All(t.owner, mode)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+	if len(doneLi) == 0 {
+		t.SendMessage(fmt.Sprintf("%s 삭제할 기록이 없습니다.", mode.Str()))
+		return
+	}
+
+	id := newNonce()
+	t.savePending(Pending{Kind: PendingReset, Mode: mode, Id: id, Expires: time.Now().Add(resetConfirmTimeout)})
+
+	t.SendMessage(fmt.Sprintf("%s 현재 시즌 완료 기록 %d개를 삭제합니다. %d분 안에 확인 필요", mode.Str(), len(doneLi), int(resetConfirmTimeout.Minutes())))
+	t.sendOptions(&DecOptMsg{
+		Title: titleReset,
+		Rcmds: []string{"확인", "취소"},
+		Ids: []string{
+			callback{Action: actReset, Mode: mode, Arg: "confirm", Nonce: id}.data(),
+			callback{Action: actReset, Mode: mode, Arg: "cancel", Nonce: id}.data(),
+		},
+		Cols: 2,
+	})
+}
+
+// confirmResetJob은 확인이면 기록을 backup 후 삭제하고 되돌리기 버튼을 보낸다.
+func (t TeleBot) confirmResetJob(update *tgbotapi.Update, cb callback) {
+	p, ok, err := t.takePending(PendingReset, cb.Mode, cb.Nonce, time.Now())
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+	if !ok {
+		t.SendMessage("삭제 요청 만료. /reset으로 다시 요청 필요")
+		return
+	}
+	if cb.Arg != "confirm" {
+		t.SendMessage("기록 삭제 취소")
+		return
+	}
+
+	doneLi, _ := t.stg.All(t.owner, p.Mode)
+	backupId, err := t.stg.DeleteAll(t.owner, p.Mode)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("%s 기록 삭제 오류 발생. %s", p.Mode.Str(), err.Error()))
+		return
+	}
+	if backupId == 0 {
+		t.SendMessage(fmt.Sprintf("%s 삭제할 기록이 없습니다.", p.Mode.Str()))
+		return
+	}
+
+	undoId := strconv.FormatInt(backupId, 10)
+	t.savePending(Pending{Kind: PendingUndo, Mode: p.Mode, Id: undoId, Expires: time.Now().Add(resetUndoPeriod)})
+	t.SendMessage(fmt.Sprintf("%s 기록 %d개 삭제 완료. 삭제한 기록은 backup #%d로 보관", p.Mode.Str(), len(doneLi), backupId))
+	t.sendOptions(&DecOptMsg{
+		Title: titleUndoReset,
+		Rcmds: []string{fmt.Sprintf("되돌리기 (%d분 이내)", int(resetUndoPeriod.Minutes()))},
+		Ids:   []string{callback{Action: actUndoReset, Mode: p.Mode, Nonce: undoId}.data()},
+	})
+}
+
+func (t TeleBot) undoResetJob(update *tgbotapi.Update, cb callback) {
+	p, ok, err := t.takePending(PendingUndo, cb.Mode, cb.Nonce, time.Now())
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+	if !ok {
+		t.SendMessage("되돌리기 가능 시간 초과")
+		return
+	}
+	backupId, err := strconv.ParseInt(p.Id, 10, 64)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("잘못된 backup id. %s", p.Id))
+		return
+	}
+
+	n, err := t.stg.RestoreBackup(t.owner, p.Mode, backupId)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("기록 복구 오류 발생. %s", err.Error()))
+		return
+	}
+	t.SendMessage(fmt.Sprintf("%s 기록 %d개 복구 완료", p.Mode.Str(), n))
+}
@@ -0,0 +1,120 @@
+package lolcheBot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requestReset은 /reset을 보내고 확인, 취소 버튼을 돌려준다.
+func requestReset(t *testing.T, bot TeleBot, tg *telegramServer) (confirm callback, cancel callback) {
+	t.Helper()
+
+	bot.resetJob()
+	buttons := tg.buttons(t)
+	if len(buttons) != 2 || buttons[0].Arg != "confirm" || buttons[1].Arg != "cancel" {
+		t.Fatalf("unexpected reset buttons %+v", buttons)
+	}
+	return buttons[0], buttons[1]
+}
+
+func lastText(tg *telegramServer) string {
+	texts := tg.texts()
+	if len(texts) == 0 {
+		return ""
+	}
+	return texts[len(texts)-1]
+}
+
+func TestConfirmResetJob(t *testing.T) {
+
+	stg := NewMemoryStorage()
+	stg.Save(1, MainMode, "k1", "덱1")
+	stg.Save(1, MainMode, "", "덱2")
+	bot, tg := newTestBot(t, stg, NewScriptedCrawler())
+
+	confirm, _ := requestReset(t, bot, tg)
+	bot.confirmResetJob(callbackUpdate(confirm), confirm)
+	if done, _ := stg.All(1, MainMode); len(done) != 0 {
+		t.Errorf("confirm should delete records. got %v", done)
+	}
+	if len(stg.backups) != 1 {
+		t.Fatalf("confirm should make a backup. got %d", len(stg.backups))
+	}
+
+	bot.confirmResetJob(callbackUpdate(confirm), confirm)
+	if msg := lastText(tg); !strings.Contains(msg, "만료") || len(stg.backups) != 1 {
+		t.Errorf("confirm should be handled once. got %q, %d backups", msg, len(stg.backups))
+	}
+}
+
+func TestCancelResetJob(t *testing.T) {
+
+	stg := NewMemoryStorage()
+	stg.Save(1, MainMode, "k1", "덱1")
+	bot, tg := newTestBot(t, stg, NewScriptedCrawler())
+
+	confirm, cancel := requestReset(t, bot, tg)
+	bot.confirmResetJob(callbackUpdate(cancel), cancel)
+	if msg := lastText(tg); msg != "기록 삭제 취소" {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	// 취소한 요청의 확인 버튼도 더는 처리하지 않는다
+	bot.confirmResetJob(callbackUpdate(confirm), confirm)
+	if done, _ := stg.All(1, MainMode); !slices.Equal(done, []DoneDeck{{Key: "k1", Name: "덱1"}}) || len(stg.backups) != 0 {
+		t.Errorf("cancel should keep records. got %v, %d backups", done, len(stg.backups))
+	}
+}
+
+func TestExpiredResetJob(t *testing.T) {
+
+	stg := NewMemoryStorage()
+	stg.Save(1, MainMode, "k1", "덱1")
+	bot, tg := newTestBot(t, stg, NewScriptedCrawler())
+
+	bot.savePending(Pending{Kind: PendingReset, Mode: MainMode, Id: "old", Expires: time.Now().Add(-time.Second)})
+	for _, nonce := range []string{"old", "unknown"} {
+		cb := callback{Action: actReset, Mode: MainMode, Arg: "confirm", Nonce: nonce}
+		bot.confirmResetJob(callbackUpdate(cb), cb)
+		if msg := lastText(tg); !strings.Contains(msg, "만료") {
+			t.Errorf("nonce %s should be refused. got %q", nonce, msg)
+		}
+	}
+	if done, _ := stg.All(1, MainMode); len(done) != 1 || len(stg.backups) != 0 {
+		t.Errorf("refused reset should keep records. got %v, %d backups", done, len(stg.backups))
+	}
+}
+
+func TestUndoResetJob(t *testing.T) {
+
+	stg := NewMemoryStorage()
+	stg.Save(1, MainMode, "k1", "덱1")
+	stg.Save(1, MainMode, "", "덱2")
+	bot, tg := newTestBot(t, stg, NewScriptedCrawler())
+
+	confirm, _ := requestReset(t, bot, tg)
+	bot.confirmResetJob(callbackUpdate(confirm), confirm)
+	buttons := tg.buttons(t)
+	if len(buttons) != 1 || buttons[0].Action != actUndoReset {
+		t.Fatalf("unexpected undo buttons %+v", buttons)
+	}
+	undo := buttons[0]
+
+	bot.undoResetJob(callbackUpdate(undo), undo)
+	want := []DoneDeck{{Key: "k1", Name: "덱1"}, {Name: "덱2"}}
+	if done, _ := stg.All(1, MainMode); !slices.Equal(done, want) {
+		t.Errorf("undo should restore records. got %v", done)
+	}
+
+	// 복구 후 지운 기록이 두 번째 되돌리기로 살아나지 않아야 한다
+	stg.Delete(1, MainMode, "k1")
+	bot.undoResetJob(callbackUpdate(undo), undo)
+	if msg := lastText(tg); msg != "되돌리기 가능 시간 초과" {
+		t.Errorf("undo should be handled once. got %q", msg)
+	}
+	if done, _ := stg.All(1, MainMode); !slices.Equal(done, want[1:]) {
+		t.Errorf("backup should be restored once. got %v", done)
+	}
+}
@@ -0,0 +1,140 @@
+package lolcheBot
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ScriptResponse는 Meta 호출 한 번에 돌려줄 결과. Stale을 지정하면 그 시각에 저장된 snapshot을 제공하는 것으로 보고한다.
+type ScriptResponse struct {
+	Decks []Deck
+	Err   error
+	Stale time.Time
+	Patch string
+}
+
+// ScriptedCrawler는 네트워크 없이 bot 로직을 검증하기 위한 DeckCrawler 구현체.
+// 모드별로 미리 지정한 ScriptResponse를 순서대로 돌려주고, 마지막 결과는 이후 호출에서도 반복된다.
+type ScriptedCrawler struct {
+	mu      sync.Mutex
+	scripts map[Mode][]ScriptResponse
+	current map[Mode]ScriptResponse
+	calls   map[Mode]int
+}
+
+func NewScriptedCrawler() *ScriptedCrawler {
+	return &ScriptedCrawler{
+		scripts: make(map[Mode][]ScriptResponse),
+		current: make(map[Mode]ScriptResponse),
+		calls:   make(map[Mode]int),
+	}
+}
+
+// Script는 mode의 Meta 호출 결과를 순서대로 추가한다.
+func (c *ScriptedCrawler) Script(mode Mode, responses ...ScriptResponse) *ScriptedCrawler {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.scripts[mode] = append(c.scripts[mode], responses...)
+	return c
+}
+
+// ScriptDecks는 name들로 덱을 만든다. Key는 "key-{name}".
+func ScriptDecks(names ...string) []Deck {
+	decks := make([]Deck, len(names))
+	for i, name := range names {
+		decks[i] = Deck{Key: "key-" + name, Name: name}
+	}
+	return decks
+}
+
+// Calls는 mode에 대해 Meta가 호출된 횟수.
+func (c *ScriptedCrawler) Calls(mode Mode) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls[mode]
+}
+
+func (c *ScriptedCrawler) next(mode Mode) ScriptResponse {
+	c.calls[mode]++
+	if len(c.scripts[mode]) > 0 {
+		c.current[mode] = c.scripts[mode][0]
+		c.scripts[mode] = c.scripts[mode][1:]
+	}
+	return c.current[mode]
+}
+
+// Modes는 main, pbe와 Script로 결과를 지정한 모드. main, pbe 다음 나머지는 이름순
+func (c *ScriptedCrawler) Modes() []Mode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modes := []Mode{MainMode, PbeMode}
+	for _, mode := range slices.Sorted(maps.Keys(c.scripts)) {
+		if !slices.Contains(modes, mode) {
+			modes = append(modes, mode)
+		}
+	}
+	return modes
+}
+
+func (c *ScriptedCrawler) Meta(mode Mode) ([]Deck, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := c.next(mode)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	if len(res.Decks) == 0 {
+		return nil, fmt.Errorf("크롤링 조회 결과 없음")
+	}
+	return slices.Clone(res.Decks), nil
+}
+
+// DeckBuilderUrl은 Meta를 호출하지 않고 마지막으로 돌려준 결과 기준으로 url을 만든다.
+func (c *ScriptedCrawler) DeckBuilderUrl(mode Mode, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deck, err := c.find(mode, key)
+	if err != nil {
+		return "", err
+	}
+	return "https://lolchess.gg/builder/guide/" + deck.Key, nil
+}
+
+func (c *ScriptedCrawler) Composition(mode Mode, key string) (Deck, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.find(mode, key)
+}
+
+func (c *ScriptedCrawler) Stale(mode Mode) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stale := c.current[mode].Stale
+	return stale, !stale.IsZero()
+}
+
+func (c *ScriptedCrawler) Patch(mode Mode) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.current[mode].Patch
+}
+
+func (c *ScriptedCrawler) find(mode Mode, key string) (Deck, error) {
+	for _, deck := range c.current[mode].Decks {
+		if deck.Key == key {
+			return deck, nil
+		}
+	}
+	return Deck{}, fmt.Errorf("%w. key: %s", ErrDeckNotInMeta, key)
+}
@@ -0,0 +1,72 @@
+package lolcheBot
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestScriptedCrawler(t *testing.T) {
+
+	errSite := errors.New("site down")
+	c := NewScriptedCrawler().
+		Script(MainMode,
+			ScriptResponse{Decks: ScriptDecks("덱1", "덱2")},
+			ScriptResponse{Err: errSite},
+			ScriptResponse{Decks: ScriptDecks("덱3")},
+		)
+
+	decs, err := c.Meta(MainMode)
+	if err != nil || !slices.Equal(scriptedNames(decs), []string{"덱1", "덱2"}) {
+		t.Fatalf("first call. got %v %v", decs, err)
+	}
+	url, err := c.DeckBuilderUrl(MainMode, "key-덱2")
+	if err != nil || url != "https://lolchess.gg/builder/guide/key-덱2" {
+		t.Errorf("unexpected url %s %v", url, err)
+	}
+	if _, err := c.DeckBuilderUrl(MainMode, "key-덱3"); !errors.Is(err, ErrDeckNotInMeta) {
+		t.Errorf("unknown key should fail with ErrDeckNotInMeta. got %v", err)
+	}
+
+	if _, err := c.Meta(MainMode); !errors.Is(err, errSite) {
+		t.Errorf("second call should fail with scripted error. got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		decs, err = c.Meta(MainMode)
+		if err != nil || !slices.Equal(scriptedNames(decs), []string{"덱3"}) {
+			t.Errorf("last response should repeat. got %v %v", decs, err)
+		}
+	}
+
+	if _, stale := c.Stale(MainMode); stale {
+		t.Error("response without Stale should not be stale")
+	}
+	fetchedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.Script(MainMode, ScriptResponse{Decks: ScriptDecks("덱3"), Stale: fetchedAt})
+	c.Meta(MainMode)
+	if got, stale := c.Stale(MainMode); !stale || !got.Equal(fetchedAt) {
+		t.Errorf("expected stale since %s. got %s %v", fetchedAt, got, stale)
+	}
+
+	if _, err := c.Meta(PbeMode); err == nil {
+		t.Error("unscripted mode should fail")
+	}
+	c.Script("doubleup", ScriptResponse{Decks: ScriptDecks("덱4")})
+	if modes := c.Modes(); !slices.Equal(modes, []Mode{MainMode, PbeMode, "doubleup"}) {
+		t.Errorf("unexpected modes %v", modes)
+	}
+
+	if c.Calls(MainMode) != 5 || c.Calls(PbeMode) != 1 {
+		t.Errorf("unexpected call count main %d pbe %d", c.Calls(MainMode), c.Calls(PbeMode))
+	}
+}
+
+func scriptedNames(decs []Deck) []string {
+	rtn := make([]string, len(decs))
+	for i, d := range decs {
+		rtn[i] = d.Name
+	}
+	return rtn
+}
@@ -1,25 +1,60 @@
 package lolcheBot
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrDeckNotInMeta는 key에 해당하는 덱이 현재 meta에 없을 때 DeckCrawler가 반환한다.
+var ErrDeckNotInMeta = errors.New("deck no longer in meta")
+
+// Stoage는 chat별 진행 기록 저장소. chatId는 기록 단위로, 그룹에서 사용자별로 기록하면 user id다.
+// meta snapshot과 권한은 모든 chat이 공유한다.
 type Stoage interface {
-	Save(mode Mode, name string) error
+	Save(chatId int64, mode Mode, key string, name string) error
 	// SaveMain(name string) error
 	// SavePbe(name string) error
-	DeleteAll(mode Mode) error
+	DeleteAll(chatId int64, mode Mode) (int64, error)             // 현재 시즌 기록을 backup 후 삭제. 반환값은 backup id이며 삭제할 기록이 없으면 0
+	RestoreBackup(chatId int64, mode Mode, id int64) (int, error) // DeleteAll로 삭제한 기록을 삭제 당시 시즌에 복구
 	// DeleteAllMain() error
 	// DeleteAllPbe() error
-	DeleteByName(mode Mode, name string) error
+	Delete(chatId int64, mode Mode, key string) error        // key로 저장된 기록 삭제
+	DeleteByName(chatId int64, mode Mode, name string) error // key 없이 name으로만 저장된 기록용
 	// DeleteMainByName(name string) error
 	// DeletePbeByName(name string) error
-	All(mode Mode) ([]string, error)
+	All(chatId int64, mode Mode) ([]DoneDeck, error) // 완료 기록 조회, 저장, 삭제는 현재 시즌 기준
 	// AllMain() ([]string, error)
 	// AllPbe() ([]string, error)
-	Mode() Mode
-	SaveMode(mode Mode)
+	Relink(chatId int64, mode Mode, name string, key string, newName string) error // key 없이 name으로만 저장된 기록에 key 부여
+	AllInSeason(chatId int64, mode Mode, season string) ([]DoneDeck, error)
+	Season(chatId int64, mode Mode) (string, error) // 현재 시즌. 시즌 도입 전이면 빈 문자열
+	SaveSeason(chatId int64, mode Mode, season string) error
+	Seasons(chatId int64, mode Mode) ([]string, error) // 현재 시즌과 완료 기록이 있는 시즌
+	Mode(chatId int64) (Mode, error)                   // 저장된 모드가 없으면 MainMode
+	SaveMode(chatId int64, mode Mode) error
+	Strategy(chatId int64) (string, error) // 미설정이면 빈 문자열
+	SaveStrategy(chatId int64, name string) error
+	SaveSelection(chatId int64, mode Mode, key string, name string) error
+	LastAttempted(chatId int64, mode Mode) (map[string]time.Time, error) // 덱 key별 마지막 도전 시각. 덱 선택과 등수 기록 중 늦은 시각
+	SaveMetaSnapshot(mode Mode, snap MetaSnapshot) error
+	MetaSnapshotAt(mode Mode, at time.Time) (MetaSnapshot, bool, error)                  // at 이전 가장 최근 기록. 없으면 false
+	SaveAttempt(chatId int64, mode Mode, attempt Attempt) error                          // 현재 시즌에 기록
+	Attempts(chatId int64, mode Mode) ([]Attempt, error)                                 // 현재 시즌 기록. 오래된 순
+	SavePending(chatId int64, pendings []Pending) error                                  // chat, Kind, Mode, Id가 같은 기록은 덮어쓰고, chat의 만료 후 하루 지난 기록은 정리한다
+	Pending(chatId int64, kind PendingKind, mode Mode, id string) (Pending, bool, error) // 만료된 기록도 반환. 없으면 false
+	Role(id int64) (Role, error)                                                         // /grant로 부여한 권한. 없으면 빈 값
+	Roles() (map[int64]Role, error)
+	SaveRole(id int64, role Role) error
+	DeleteRole(id int64) error
 }
 
 type DeckCrawler interface {
-	Meta(mode Mode) (dec []string, err error)
-	DeckBuilderUrl(mode Mode, id int) (string, error)
+	Modes() []Mode // meta를 조회할 수 있는 모드
+	Meta(mode Mode) (dec []Deck, err error)
+	DeckBuilderUrl(mode Mode, key string) (string, error)
+	Composition(mode Mode, key string) (Deck, error)
+	Stale(mode Mode) (fetchedAt time.Time, stale bool) // 사이트 접속 실패로 저장된 meta를 사용 중이면 그 수집 시각
+	Patch(mode Mode) string                            // 최근 meta의 패치 버전. 알 수 없으면 빈 문자열
 	// DeckUrl(mode Mode, id string) (string, error)
 	// UpdateCssPath(target string) error
 }
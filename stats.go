@@ -0,0 +1,134 @@
+package lolcheBot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// progress는 현재 meta 기준 진행 현황
+type progress struct {
+	total        int // meta 덱 수
+	done         int // meta 덱 중 완료한 덱 수
+	specialTotal int // [증강] 등 '['로 시작하는 덱
+	specialDone  int
+
+	attemptedDone int // 시도 기록이 있는 완료 덱 수
+	doneAttempts  int // 완료 덱의 시도 수
+
+	oldest        *Deck     // 완료하지 못한 덱 중 가장 먼저 시도한 덱
+	oldestSince   time.Time // oldest의 첫 시도 시각
+	oldestAttempt int       // oldest의 시도 수
+}
+
+// newProgress는 meta 덱 목록과 완료 기록, 시도 기록으로 진행 현황을 계산한다.
+// 평균 시도 수는 시도 기록이 있는 완료 덱만으로 계산한다. (등수 기록 도입 전 완료한 덱 제외)
+func newProgress(decLi []Deck, doneLi []DoneDeck, attempts []Attempt) progress {
+	p := progress{total: len(decLi)}
+
+	m := doneSet(decLi, doneLi)
+	for i, deck := range decLi {
+		special := strings.HasPrefix(deck.Name, "[")
+		if special {
+			p.specialTotal++
+		}
+		if m[i] {
+			p.done++
+			if special {
+				p.specialDone++
+			}
+		}
+	}
+
+	byKey := make(map[string][]Attempt)
+	for _, a := range attempts {
+		byKey[a.Key] = append(byKey[a.Key], a)
+	}
+	for _, d := range doneLi {
+		if n := len(byKey[d.Key]); d.Key != "" && n > 0 {
+			p.attemptedDone++
+			p.doneAttempts += n
+		}
+	}
+	for i := range decLi {
+		li := byKey[decLi[i].Key]
+		if m[i] || len(li) == 0 {
+			continue
+		}
+		if p.oldest == nil || li[0].PlayedAt.Before(p.oldestSince) { // attempts는 오래된 순
+			p.oldest = &decLi[i]
+			p.oldestSince = li[0].PlayedAt
+			p.oldestAttempt = len(li)
+		}
+	}
+	return p
+}
+
+// text는 /stats 문구. ex)
+//
+//	[정규 모드] 진행 현황
+//	완료: 12/30 (40%)
+//	■■■■□□□□□□
+//	증강 덱: 3개 완료, 2개 남음
+//	완료한 덱 평균 시도: 2.5회
+//	가장 오래 도전 중인 덱: 덱3 (3일 전부터 4회 시도)
+func (p progress) text(mode Mode, now time.Time) string {
+	percent := 0
+	if p.total > 0 {
+		percent = p.done * 100 / p.total
+	}
+
+	lines := []string{
+		fmt.Sprintf("[%s] 진행 현황", mode.Str()),
+		fmt.Sprintf("완료: %d/%d (%d%%)", p.done, p.total, percent),
+		progressBar(p.done, p.total, 10),
+		fmt.Sprintf("증강 덱: %d개 완료, %d개 남음", p.specialDone, p.specialTotal-p.specialDone),
+	}
+	if p.attemptedDone > 0 {
+		lines = append(lines, fmt.Sprintf("완료한 덱 평균 시도: %.1f회", float64(p.doneAttempts)/float64(p.attemptedDone)))
+	} else {
+		lines = append(lines, "완료한 덱 평균 시도: 기록 없음")
+	}
+	if p.oldest != nil {
+		lines = append(lines, fmt.Sprintf("가장 오래 도전 중인 덱: %s (%s부터 %d회 시도)", p.oldest.Name, ago(now.Sub(p.oldestSince)), p.oldestAttempt))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// progressBar는 done/total 비율을 width 칸으로 표시한다. ex) ■■■■□□□□□□
+func progressBar(done int, total int, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = done * width / total
+	}
+	return strings.Repeat("■", filled) + strings.Repeat("□", width-filled)
+}
+
+func (t TeleBot) statsJob() {
+	mode, ok := t.currentMode()
+	if !ok {
+		return
+	}
+
+	decLi, err := t.dc.Meta(mode)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+	doneLi, err := t.stg.All(t.owner, mode)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+	attempts, err := t.stg.Attempts(t.owner, mode)
+	if err != nil {
+		t.SendMessage(fmt.Sprintf("오류 발생 %s", err.Error()))
+		return
+	}
+
+	msg := newProgress(decLi, doneLi, attempts).text(mode, time.Now())
+	if fetchedAt, stale := t.dc.Stale(mode); stale {
+		msg += fmt.Sprintf("\n(%s 저장된 덱 정보 기준)", ago(time.Since(fetchedAt)))
+	}
+	t.SendMessage(msg)
+}
@@ -0,0 +1,61 @@
+package lolcheBot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local)
+	decLi := []Deck{
+		{Key: "k1", Name: "덱1"},
+		{Key: "k2", Name: "[증강] 덱2"},
+		{Key: "k3", Name: "[증강] 덱3"},
+		{Key: "k4", Name: "덱4"},
+		{Key: "k5", Name: "덱5"},
+	}
+	doneLi := []DoneDeck{{Key: "k1", Name: "덱1"}, {Name: "[증강] 덱2"}, {Key: "k9", Name: "meta에서 빠진 덱"}}
+	attempts := []Attempt{
+		{Key: "k4", Placement: 6, PlayedAt: now.Add(-72 * time.Hour)},
+		{Key: "k1", Placement: 5, PlayedAt: now.Add(-48 * time.Hour)},
+		{Key: "k5", Placement: 7, PlayedAt: now.Add(-30 * time.Hour)},
+		{Key: "k1", Placement: 2, PlayedAt: now.Add(-24 * time.Hour)},
+		{Key: "k9", Placement: 1, PlayedAt: now.Add(-2 * time.Hour)},
+		{Key: "k4", Placement: 5, PlayedAt: now.Add(-time.Hour)},
+	}
+
+	got := newProgress(decLi, doneLi, attempts).text(MainMode, now)
+	want := "[정규 모드] 진행 현황\n" +
+		"완료: 2/5 (40%)\n" +
+		"■■■■□□□□□□\n" +
+		"증강 덱: 1개 완료, 1개 남음\n" +
+		"완료한 덱 평균 시도: 1.5회\n" +
+		"가장 오래 도전 중인 덱: 덱4 (3일 전부터 2회 시도)"
+	if got != want {
+		t.Errorf("unexpected text\n%s", got)
+	}
+}
+
+func TestProgressEmpty(t *testing.T) {
+
+	got := newProgress(nil, nil, nil).text(PbeMode, time.Now())
+	want := "[pbe 모드] 진행 현황\n" +
+		"완료: 0/0 (0%)\n" +
+		"□□□□□□□□□□\n" +
+		"증강 덱: 0개 완료, 0개 남음\n" +
+		"완료한 덱 평균 시도: 기록 없음"
+	if got != want {
+		t.Errorf("unexpected text\n%s", got)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+
+	if got := progressBar(3, 3, 10); got != "■■■■■■■■■■" {
+		t.Errorf("unexpected bar %s", got)
+	}
+	if got := progressBar(1, 3, 10); got != "■■■□□□□□□□" {
+		t.Errorf("unexpected bar %s", got)
+	}
+}
@@ -0,0 +1,122 @@
+package lolcheBot
+
+import (
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Strategy는 추천 순서를 정하는 방식. 반환한 덱 index 순서대로 미완료 일반 덱 하나와 미완료 증강 덱 전부를 추천한다.
+type Strategy interface {
+	Name() string
+	Desc() string
+	// Order는 decLi의 index를 추천 우선순위대로 정렬해 반환한다. lastAttempted는 덱 key별 마지막 도전 시각.
+	Order(decLi []Deck, lastAttempted map[string]time.Time) []int
+}
+
+const defaultStrategy = "bottom"
+
+func allStrategies() []Strategy {
+	return []Strategy{
+		bottomUp{},
+		topDown{},
+		tierFirst{},
+		newRandomDraw(rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0))),
+		leastRecent{},
+	}
+}
+
+// findStrategy는 이름으로 전략을 찾는다. 없으면 기본 전략(bottom)을 반환한다.
+func findStrategy(name string) (Strategy, bool) {
+	for _, s := range allStrategies() {
+		if s.Name() == name {
+			return s, true
+		}
+	}
+	return bottomUp{}, false
+}
+
+// bottomUp : 목록 하위 덱부터 순차 도전 (기본)
+type bottomUp struct{}
+
+func (bottomUp) Name() string { return "bottom" }
+func (bottomUp) Desc() string { return "하위 덱부터" }
+
+func (bottomUp) Order(decLi []Deck, _ map[string]time.Time) []int {
+	idx := make([]int, len(decLi))
+	for i := range idx {
+		idx[i] = len(decLi) - 1 - i
+	}
+	return idx
+}
+
+// topDown : 목록 상위 덱부터 순차 도전
+type topDown struct{}
+
+func (topDown) Name() string { return "top" }
+func (topDown) Desc() string { return "상위 덱부터" }
+
+func (topDown) Order(decLi []Deck, _ map[string]time.Time) []int {
+	idx := make([]int, len(decLi))
+	for i := range idx {
+		idx[i] = i
+	}
+	return idx
+}
+
+// tierFirst : 높은 티어부터. 같은 티어는 목록 순서
+type tierFirst struct{}
+
+func (tierFirst) Name() string { return "tier" }
+func (tierFirst) Desc() string { return "높은 티어부터" }
+
+func (tierFirst) Order(decLi []Deck, _ map[string]time.Time) []int {
+	idx := topDown{}.Order(decLi, nil)
+	slices.SortStableFunc(idx, func(a, b int) int {
+		return tierRank(decLi[a].Tier) - tierRank(decLi[b].Tier)
+	})
+	return idx
+}
+
+// tierRank는 티어의 정렬 순위. 모르는 티어는 가장 뒤로.
+func tierRank(tier string) int {
+	rank := slices.Index([]string{"OP", "S", "A", "B", "C", "D"}, tier)
+	if rank == -1 {
+		return 100
+	}
+	return rank
+}
+
+// randomDraw : 무작위 추첨
+type randomDraw struct {
+	rnd *rand.Rand
+}
+
+func newRandomDraw(rnd *rand.Rand) randomDraw {
+	return randomDraw{rnd: rnd}
+}
+
+func (randomDraw) Name() string { return "random" }
+func (randomDraw) Desc() string { return "무작위 추첨" }
+
+func (r randomDraw) Order(decLi []Deck, _ map[string]time.Time) []int {
+	idx := topDown{}.Order(decLi, nil)
+	r.rnd.Shuffle(len(idx), func(i, j int) {
+		idx[i], idx[j] = idx[j], idx[i]
+	})
+	return idx
+}
+
+// leastRecent : 가장 오래전에 도전한 덱부터. 도전한 적 없는 덱이 가장 먼저이고, 동률은 하위 덱부터
+type leastRecent struct{}
+
+func (leastRecent) Name() string { return "oldest" }
+func (leastRecent) Desc() string { return "가장 오래전에 도전한 덱부터" }
+
+func (leastRecent) Order(decLi []Deck, lastAttempted map[string]time.Time) []int {
+	idx := bottomUp{}.Order(decLi, nil)
+	slices.SortStableFunc(idx, func(a, b int) int {
+		return lastAttempted[decLi[a].Key].Compare(lastAttempted[decLi[b].Key])
+	})
+	return idx
+}
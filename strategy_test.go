@@ -0,0 +1,112 @@
+package lolcheBot
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+var strategyDecks = []Deck{
+	{Key: "k-덱0", Name: "덱0", Tier: "A"},
+	{Key: "k-[증강] 덱1", Name: "[증강] 덱1", Tier: "S"},
+	{Key: "k-덱2", Name: "덱2", Tier: "S"},
+	{Key: "k-덱3", Name: "덱3", Tier: "B"},
+	{Key: "k-[상징] 덱4", Name: "[상징] 덱4", Tier: "A"},
+	{Key: "k-덱5", Name: "덱5", Tier: ""},
+}
+
+func TestStrategyOrder(t *testing.T) {
+
+	now := time.Now()
+	lastAttempted := map[string]time.Time{
+		"k-덱5":      now.Add(-time.Hour),
+		"k-덱3":      now.Add(-2 * time.Hour),
+		"k-[상징] 덱4": now,
+		"덱0":        now, // 이름이 아니라 key로 찾는다
+	}
+
+	tests := []struct {
+		st   Strategy
+		want []int
+	}{
+		{bottomUp{}, []int{5, 4, 3, 2, 1, 0}},
+		{topDown{}, []int{0, 1, 2, 3, 4, 5}},
+		{tierFirst{}, []int{1, 2, 0, 4, 3, 5}},
+		{leastRecent{}, []int{2, 1, 0, 3, 5, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.st.Name(), func(t *testing.T) {
+			if got := tt.st.Order(strategyDecks, lastAttempted); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v. got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("random", func(t *testing.T) {
+		st := newRandomDraw(rand.New(rand.NewPCG(1, 2)))
+		got := st.Order(strategyDecks, nil)
+		if again := newRandomDraw(rand.New(rand.NewPCG(1, 2))).Order(strategyDecks, nil); !slices.Equal(got, again) {
+			t.Errorf("same seed should give same order. %v %v", got, again)
+		}
+		sorted := slices.Clone(got)
+		slices.Sort(sorted)
+		if !slices.Equal(sorted, []int{0, 1, 2, 3, 4, 5}) {
+			t.Errorf("order should be a permutation. got %v", got)
+		}
+	})
+}
+
+func TestMakeDecRcmd(t *testing.T) {
+
+	done := []DoneDeck{{Name: "덱5"}, {Name: "[증강] 덱1"}}
+
+	tests := []struct {
+		st          Strategy
+		wantNormal  string
+		wantSpecial []string
+	}{
+		{bottomUp{}, "덱3", []string{"[상징] 덱4"}},
+		{topDown{}, "덱0", []string{"[상징] 덱4"}},
+		{tierFirst{}, "덱2", []string{"[상징] 덱4"}},
+		{leastRecent{}, "덱3", []string{"[상징] 덱4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.st.Name(), func(t *testing.T) {
+			rcmd := makeDecRcmd(MainMode, tt.st, strategyDecks, done, nil)
+			if len(rcmd) != 2 {
+				t.Fatalf("expected normal and special message. got %+v", rcmd)
+			}
+			if rcmd[0].Title != titleNormalDeck || !slices.Equal(rcmd[0].Rcmds, []string{tt.wantNormal}) {
+				t.Errorf("expected normal deck %s. got %+v", tt.wantNormal, rcmd[0])
+			}
+			if rcmd[1].Title != titleSpecDeck || !slices.Equal(rcmd[1].Rcmds, tt.wantSpecial) {
+				t.Errorf("expected special decks %v. got %+v", tt.wantSpecial, rcmd[1])
+			}
+			if cb, _ := decodeCallback(rcmd[0].Ids[0]); cb.Action != actSelect || cb.Key != "k-"+tt.wantNormal || cb.Mode != MainMode {
+				t.Errorf("callback should select the deck key. got %s", rcmd[0].Ids[0])
+			}
+		})
+	}
+
+	t.Run("all completed", func(t *testing.T) {
+		all := []DoneDeck{{Name: "덱0"}, {Name: "[증강] 덱1"}, {Name: "덱2"}, {Name: "덱3"}, {Name: "[상징] 덱4"}, {Name: "덱5"}}
+		if rcmd := makeDecRcmd(MainMode, bottomUp{}, strategyDecks, all, nil); len(rcmd) != 0 {
+			t.Errorf("expected no recommendation. got %+v", rcmd)
+		}
+	})
+}
+
+func TestFindStrategy(t *testing.T) {
+
+	for _, st := range allStrategies() {
+		if found, ok := findStrategy(st.Name()); !ok || found.Name() != st.Name() {
+			t.Errorf("strategy %s not found", st.Name())
+		}
+	}
+	if st, ok := findStrategy("unknown"); ok || st.Name() != defaultStrategy {
+		t.Errorf("unknown strategy should fall back to default. got %s %v", st.Name(), ok)
+	}
+}
@@ -1,9 +1,17 @@
 package lolcheBot
 
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
 type DecOptMsg struct {
-	Title string
-	Rcmds []string
-	Ids   []int
+	Title  string
+	Rcmds  []string
+	Ids    []string // 버튼 callback data
+	Labels []string // 버튼에 표시할 문구. 비어있으면 Rcmds 사용
+	Cols   int      // 한 줄에 놓을 버튼 수. 0이면 한 줄에 하나
 }
 
 type Command string
@@ -16,6 +24,12 @@ const (
 	reset     Command = "/reset"
 	done      Command = "/done"
 	fix       Command = "/fix"
+	strategy  Command = "/strategy"
+	history   Command = "/history"
+	season    Command = "/season"
+	stats     Command = "/stats"
+	grant     Command = "/grant"
+	revoke    Command = "/revoke"
 )
 
 func allCommands() []Command {
@@ -26,6 +40,12 @@ func allCommands() []Command {
 		updating,
 		reset,
 		done,
+		strategy,
+		history,
+		season,
+		stats,
+		grant,
+		revoke,
 		fix,
 	}
 }
@@ -46,19 +66,128 @@ const (
 	titleNormalDeck       string = "일반 덱"
 	titleSpecDeck         string = "증강 덱"
 	titleWhetherCompleted        = "완료 여부"
+	titleStrategy                = "추천 방식"
+	titleSeason                  = "시즌 기록"
+	titleMode                    = "모드 선택"
+	titlePlacement               = "등수 기록"
+	titleReset                   = "기록 삭제 확인"
+	titleUndoReset               = "기록 삭제 취소"
 )
 
-type Mode bool
+// Mode는 meta 페이지 단위의 게임 모드. main, pbe 외의 모드(더블업, 초고속 모드 등)는 crawler 설정에 meta url과 함께 추가한다.
+type Mode string
 
 const (
-	MainMode Mode = true
-	PbeMode  Mode = false
+	MainMode Mode = "main"
+	PbeMode  Mode = "pbe"
 )
 
+// modeName은 모드 이름 규칙. 파일명과 버튼 callback data로도 쓰이므로 영문 소문자, 숫자, -, _만 허용하고,
+// 덱 key와 함께 callback data 64byte 제한에 들어가도록 16자로 제한한다.
+var modeName = regexp.MustCompile(`^[a-z0-9_-]{1,16}$`)
+
+// ParseMode는 name이 모드 이름 규칙에 맞는지 확인한다.
+func ParseMode(name string) (Mode, error) {
+	if !modeName.MatchString(name) {
+		return "", fmt.Errorf("잘못된 모드 이름. %q (영문 소문자, 숫자, -, _ 16자 이내)", name)
+	}
+	return Mode(name), nil
+}
+
 func (m Mode) Str() string {
-	if m {
+	switch m {
+	case MainMode:
 		return "정규 모드"
-	} else {
+	case PbeMode:
 		return "pbe 모드"
+	default:
+		return string(m) + " 모드"
 	}
 }
+
+// DoneDeck은 완료 기록. Key는 lolchess.gg의 teamBuilderKey이며 key 도입 전 기록은 빈 값.
+type DoneDeck struct {
+	Key  string
+	Name string
+}
+
+// Deck는 추천 덱의 구성 및 통계 정보
+type Deck struct {
+	Key      string
+	Name     string
+	Tier     string // S, A, B...
+	Rank     int    // meta 페이지 내 순위
+	Stats    DeckStats
+	Units    []Unit
+	Traits   []Trait
+	Augments []string // 추천 증강
+	Carousel []string // 회전초밥 우선순위
+}
+
+type DeckStats struct {
+	AvgPlacement float64 // 평균 등수
+	Top4Rate     float64 // 0~1
+	WinRate      float64 // 0~1
+	PickRate     float64 // 0~1
+	Plays        int
+}
+
+type Unit struct {
+	Name  string
+	Cost  int
+	Star  int
+	Items []string
+}
+
+type Trait struct {
+	Name  string
+	Count int
+	Style string // bronze, silver, gold, unique...
+}
+
+// MetaSnapshot은 특정 시각의 meta 덱 목록. Decks는 meta 페이지 순서이며 Key, Name, Tier만 기록한다.
+type MetaSnapshot struct {
+	Patch   string // ex) 15.4. 알 수 없으면 빈 값
+	TakenAt time.Time
+	Decks   []Deck
+}
+
+// Attempt는 덱으로 한 게임의 결과
+type Attempt struct {
+	Key       string
+	Name      string
+	Placement int // 1~8등
+	PlayedAt  time.Time
+}
+
+// PendingKind는 버튼을 눌렀을 때 처리할 대상의 종류
+type PendingKind string
+
+const (
+	PendingDeck   PendingKind = "deck"   // 추천, 선택한 덱. Id: 덱 key
+	PendingDone   PendingKind = "done"   // /done 완료 목록. Id: 목록 nonce.index
+	PendingSeason PendingKind = "season" // /season 시즌 목록. Id: 목록 nonce.index, Name: 시즌
+	PendingReset  PendingKind = "reset"  // /reset 삭제 확인. Id: 요청 nonce
+	PendingUndo   PendingKind = "undo"   // /reset 삭제 되돌리기. Id: backup id
+)
+
+// PendingKeep은 만료된 Pending을 남겨두는 기간. 그동안은 버튼을 누르면 만료 안내를 받는다.
+const PendingKeep = 24 * time.Hour
+
+// Pending은 버튼을 보낼 때 저장해 두는 대화 상태. 재기동 후에도 Expires까지 버튼을 처리할 수 있다.
+type Pending struct {
+	Kind    PendingKind
+	Mode    Mode
+	Id      string // 버튼 callback data의 Key
+	Key     string // 덱 key
+	Name    string
+	Expires time.Time
+}
+
+// Role은 bot 사용 권한. user id 또는 chat id에 부여한다.
+type Role string
+
+const (
+	RoleMember Role = "member" // 명령어와 버튼 사용
+	RoleAdmin  Role = "admin"  // 기록 삭제, 모드, 시즌 변경과 권한 관리. user id에만 부여한다.
+)
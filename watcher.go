@@ -0,0 +1,164 @@
+package lolcheBot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// metaWatcher는 주기적으로 모드별 meta를 조회하여 이전 조회 결과와 달라진 점을 알린다.
+// 첫 조회 결과는 비교 기준으로만 사용하고, snapshot으로 대체된 결과는 비교하지 않는다.
+type metaWatcher struct {
+	dc       DeckCrawler
+	stg      Stoage
+	chatId   int64 // 완료한 덱이 meta에서 빠졌는지 확인할 기록
+	interval time.Duration
+	notify   func(msg string)
+	prev     map[Mode][]Deck
+}
+
+func newMetaWatcher(dc DeckCrawler, stg Stoage, chatId int64, interval time.Duration, notify func(msg string)) *metaWatcher {
+	return &metaWatcher{
+		dc:       dc,
+		stg:      stg,
+		chatId:   chatId,
+		interval: interval,
+		notify:   notify,
+		prev:     make(map[Mode][]Deck),
+	}
+}
+
+func (w *metaWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, mode := range w.dc.Modes() {
+			if msg := w.check(mode); msg != "" {
+				w.notify(msg)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// check는 mode의 meta를 조회하여 이전 결과와 비교한 알림 문구를 반환한다. 변경이 없으면 빈 문자열
+func (w *metaWatcher) check(mode Mode) string {
+	decLi, err := w.dc.Meta(mode)
+	if err != nil {
+		log.Printf("%s meta 조회 실패. %s", mode.Str(), err.Error())
+		return ""
+	}
+	if _, stale := w.dc.Stale(mode); stale {
+		return ""
+	}
+
+	prev, ok := w.prev[mode]
+	w.prev[mode] = decLi
+	if !ok {
+		return ""
+	}
+
+	doneLi, err := w.stg.All(w.chatId, mode)
+	if err != nil {
+		log.Printf("%s 완료 기록 조회 실패. %s", mode.Str(), err.Error())
+	}
+	return diffMeta(prev, decLi, doneLi).message(mode)
+}
+
+// metaDiff는 두 meta 조회 결과의 차이. 덱은 key로 비교한다.
+type metaDiff struct {
+	added       []Deck
+	removed     []Deck
+	tierMoves   []tierMove
+	doneRemoved []Deck // removed 중 완료한 덱
+}
+
+type tierMove struct {
+	deck Deck
+	from string
+}
+
+func diffMeta(prev []Deck, cur []Deck, doneLi []DoneDeck) metaDiff {
+	var diff metaDiff
+
+	prevByKey := make(map[string]Deck, len(prev))
+	for _, deck := range prev {
+		prevByKey[deck.Key] = deck
+	}
+	curKeys := make(map[string]bool, len(cur))
+	for _, deck := range cur {
+		curKeys[deck.Key] = true
+		before, ok := prevByKey[deck.Key]
+		if !ok {
+			diff.added = append(diff.added, deck)
+		} else if before.Tier != deck.Tier {
+			diff.tierMoves = append(diff.tierMoves, tierMove{deck: deck, from: before.Tier})
+		}
+	}
+	for _, deck := range prev {
+		if !curKeys[deck.Key] {
+			diff.removed = append(diff.removed, deck)
+		}
+	}
+
+	doneIdx := doneSet(diff.removed, doneLi)
+	for i, deck := range diff.removed {
+		if doneIdx[i] {
+			diff.doneRemoved = append(diff.doneRemoved, deck)
+		}
+	}
+	return diff
+}
+
+func (d metaDiff) empty() bool {
+	return len(d.added) == 0 && len(d.removed) == 0 && len(d.tierMoves) == 0
+}
+
+// message는 알림 문구. ex)
+//
+//	[정규 모드] 메타 변경
+//	추가: 덱1, 덱2
+//	제외: 덱3
+//	티어 변동: 덱4 A→S
+//	완료한 덱 중 제외: 덱3
+func (d metaDiff) message(mode Mode) string {
+	if d.empty() {
+		return ""
+	}
+
+	lines := []string{fmt.Sprintf("[%s] 메타 변경", mode.Str())}
+	if len(d.added) > 0 {
+		lines = append(lines, "추가: "+deckNames(d.added))
+	}
+	if len(d.removed) > 0 {
+		lines = append(lines, "제외: "+deckNames(d.removed))
+	}
+	if len(d.tierMoves) > 0 {
+		moves := make([]string, len(d.tierMoves))
+		for i, m := range d.tierMoves {
+			moves[i] = fmt.Sprintf("%s %s→%s", m.deck.Name, tierText(m.from), tierText(m.deck.Tier))
+		}
+		lines = append(lines, "티어 변동: "+strings.Join(moves, ", "))
+	}
+	if len(d.doneRemoved) > 0 {
+		lines = append(lines, "완료한 덱 중 제외: "+deckNames(d.doneRemoved))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func deckNames(decLi []Deck) string {
+	names := make([]string, len(decLi))
+	for i, deck := range decLi {
+		names[i] = deck.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func tierText(tier string) string {
+	if tier == "" {
+		return "-"
+	}
+	return tier
+}
@@ -0,0 +1,76 @@
+package lolcheBot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDiffMeta(t *testing.T) {
+
+	prev := []Deck{
+		{Key: "k1", Name: "덱1", Tier: "S"},
+		{Key: "k2", Name: "덱2", Tier: "A"},
+		{Key: "k3", Name: "덱3", Tier: "B"},
+		{Key: "k4", Name: "덱4", Tier: "B"},
+	}
+	cur := []Deck{
+		{Key: "k1", Name: "덱1", Tier: "A"},
+		{Key: "k4", Name: "덱4", Tier: "B"},
+		{Key: "k5", Name: "덱5", Tier: "S"},
+	}
+	doneLi := []DoneDeck{{Key: "k2", Name: "덱2"}, {Name: "덱3"}, {Key: "k4", Name: "덱4"}}
+
+	got := diffMeta(prev, cur, doneLi).message(MainMode)
+	want := "[정규 모드] 메타 변경\n" +
+		"추가: 덱5\n" +
+		"제외: 덱2, 덱3\n" +
+		"티어 변동: 덱1 S→A\n" +
+		"완료한 덱 중 제외: 덱2, 덱3"
+	if got != want {
+		t.Errorf("unexpected message\n%s", got)
+	}
+
+	if msg := diffMeta(prev, prev, doneLi).message(MainMode); msg != "" {
+		t.Errorf("same meta should not notify. got %s", msg)
+	}
+	if msg := diffMeta(prev[:1], []Deck{{Key: "k1", Name: "덱1"}}, nil).message(PbeMode); msg != "[pbe 모드] 메타 변경\n티어 변동: 덱1 S→-" {
+		t.Errorf("unexpected message %s", msg)
+	}
+}
+
+func TestMetaWatcherCheck(t *testing.T) {
+
+	stg := NewMemoryStorage()
+	stg.Save(1, MainMode, "k2", "덱2")
+	stg.Save(2, MainMode, "k1", "덱1")
+	dc := NewScriptedCrawler().Script(MainMode,
+		ScriptResponse{Decks: []Deck{{Key: "k1", Name: "덱1"}, {Key: "k2", Name: "덱2"}}},
+		ScriptResponse{Err: errors.New("site down")},
+		ScriptResponse{Decks: []Deck{{Key: "k1", Name: "덱1"}}, Stale: time.Now()},
+		ScriptResponse{Decks: []Deck{{Key: "k1", Name: "덱1"}}},
+	)
+	w := newMetaWatcher(dc, stg, 1, time.Minute, nil)
+
+	if msg := w.check(MainMode); msg != "" {
+		t.Errorf("first check should only record meta. got %s", msg)
+	}
+
+	if msg := w.check(MainMode); msg != "" {
+		t.Errorf("failed check should not notify. got %s", msg)
+	}
+
+	if msg := w.check(MainMode); msg != "" {
+		t.Errorf("stale meta should not be compared. got %s", msg)
+	}
+
+	if msg := w.check(MainMode); msg != "[정규 모드] 메타 변경\n제외: 덱2\n완료한 덱 중 제외: 덱2" {
+		t.Errorf("unexpected message %s", msg)
+	}
+	if msg := w.check(MainMode); msg != "" {
+		t.Errorf("unchanged meta should not notify. got %s", msg)
+	}
+	if msg := w.check(PbeMode); msg != "" {
+		t.Errorf("modes should be compared separately. got %s", msg)
+	}
+}